@@ -0,0 +1,136 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+
+	"github.com/dacb/goabe/plugins"
+)
+
+// Simultaneous activates all of the agents at once: in the thread hook of
+// SubStep each thread steps its share of the agents, which compute their
+// next state, then the core hook commits every agent's next state and
+// applies the population's changes.
+type Simultaneous[A Committer] struct {
+	Population  *Population[A]
+	SubStep     int
+	Description string
+}
+
+// Hooks returns the hooks for the plugin's GetHooks.
+func (s Simultaneous[A]) Hooks() []plugins.Hook {
+	return []plugins.Hook{{
+		SubStep:     s.SubStep,
+		Thread:      s.step,
+		Core:        s.commit,
+		Description: describe(s.Description, "simultaneous step and commit"),
+	}}
+}
+
+func (s Simultaneous[A]) step(ctx context.Context, id int, name string) error {
+	ctx = context.WithValue(ctx, "thread", id)
+	for _, agent := range s.Population.Chunk(id, threads(ctx)) {
+		if err := agent.Step(ctx); err != nil {
+			return fmt.Errorf("agent %d: %w", agent.ID(), err)
+		}
+	}
+	return nil
+}
+
+func (s Simultaneous[A]) commit(ctx context.Context) error {
+	for _, agent := range s.Population.Agents() {
+		agent.Commit()
+	}
+	s.Population.Apply()
+	return nil
+}
+
+// RandomSequential activates the agents one after the other in a new
+// random order each step, drawn from Rand, so each agent sees the changes
+// made by the agents before it.  It runs in the core hook of SubStep, as
+// the agents cannot step in parallel.  An agent killed by one stepped
+// before it does not step.
+type RandomSequential[A Agent] struct {
+	Population  *Population[A]
+	SubStep     int
+	Rand        *rand.Rand
+	Description string
+}
+
+// Hooks returns the hooks for the plugin's GetHooks.
+func (s RandomSequential[A]) Hooks() []plugins.Hook {
+	return []plugins.Hook{{
+		SubStep:     s.SubStep,
+		Core:        s.step,
+		Description: describe(s.Description, "random sequential step"),
+	}}
+}
+
+func (s RandomSequential[A]) step(ctx context.Context) error {
+	order := append([]A(nil), s.Population.Agents()...)
+	s.Rand.Shuffle(len(order), func(i, j int) { order[i], order[j] = order[j], order[i] })
+	for _, agent := range order {
+		if s.Population.dying(agent.ID()) {
+			continue
+		}
+		if err := agent.Step(ctx); err != nil {
+			return fmt.Errorf("agent %d: %w", agent.ID(), err)
+		}
+	}
+	s.Population.Apply()
+	return nil
+}
+
+// Staged activates the agents in Stages stages, stage i in substep
+// SubStep+i.  In each stage every thread runs the stage for its share of
+// the agents, so all of the agents finish a stage before any begins the
+// next, and the population's changes are applied at the end of each stage.
+// The engine refuses to run a stage past the last substep.
+type Staged[A Stager] struct {
+	Population  *Population[A]
+	SubStep     int
+	Stages      int
+	Description string
+}
+
+// Hooks returns the hooks for the plugin's GetHooks.
+func (s Staged[A]) Hooks() []plugins.Hook {
+	var hooks []plugins.Hook
+	for stage := 0; stage < s.Stages; stage++ {
+		stage := stage
+		hooks = append(hooks, plugins.Hook{
+			SubStep: s.SubStep + stage,
+			Thread: func(ctx context.Context, id int, name string) error {
+				ctx = context.WithValue(ctx, "thread", id)
+				for _, agent := range s.Population.Chunk(id, threads(ctx)) {
+					if err := agent.Stage(ctx, stage); err != nil {
+						return fmt.Errorf("agent %d stage %d: %w", agent.ID(), stage, err)
+					}
+				}
+				return nil
+			},
+			Core: func(ctx context.Context) error {
+				s.Population.Apply()
+				return nil
+			},
+			Description: describe(s.Description, fmt.Sprintf("stage %d", stage)),
+		})
+	}
+	return hooks
+}
+
+// threads returns the number of the engine's threads from the context
+func threads(ctx context.Context) int {
+	if n, ok := ctx.Value("threads").(int); ok && n > 0 {
+		return n
+	}
+	return 1
+}
+
+func describe(description, regime string) string {
+	if description == "" {
+		return "agents " + regime
+	}
+	return description + " (" + regime + ")"
+}
@@ -0,0 +1,75 @@
+// Package agents gives plugins agents, populations of them and the
+// standard ways of activating them in each step, parallelized over the
+// engine's threads.  A plugin keeps its agents in a Population and returns
+// the hooks of an activation schedule from GetHooks:
+//
+//	m.sheep = agents.NewPopulation[*Sheep]()
+//	...
+//	func (m *model) GetHooks() []plugins.Hook {
+//		return agents.Simultaneous[*Sheep]{Population: m.sheep, Description: "graze"}.Hooks()
+//	}
+//
+// The activation regimes are
+//
+//   - Simultaneous: every agent computes its next state from the current
+//     ones in a thread hook, then all of them commit it in the core hook,
+//     the way life computes aliveNext before updating alive.
+//   - RandomSequential: the agents step one at a time in a random order,
+//     each seeing the changes of the ones before it.  It runs on the core.
+//   - Staged: each step is split into stages run in successive substeps;
+//     all of the agents finish a stage before any starts the next.
+//
+// Agents born into or dying out of a population during a substep join or
+// leave it at the substep's barrier, so every hook of a substep sees the
+// same agents.  The schedules apply the changes in their core hooks; a
+// plugin that changes a population from hooks of its own adds the
+// population's Hook for the substep to its hooks.  An agent gives birth from its Step with
+//
+//	m.sheep.Birth(agents.Thread(ctx), s.id, func(id agents.ID) *Sheep {
+//		return &Sheep{id: id, energy: s.energy / 2}
+//	})
+package agents
+
+import "context"
+
+// ID identifies an agent in its population.
+type ID uint64
+
+// Agent is an actor in a model.
+type Agent interface {
+	ID() ID
+	// Step is the agent's behavior in a step.  Under Simultaneous
+	// activation it may only read the other agents' current state.
+	Step(ctx context.Context) error
+}
+
+// Committer is an agent for Simultaneous activation, whose Step computes
+// its next state and Commit makes it the current one.
+type Committer interface {
+	Agent
+	Commit()
+}
+
+// Stager is an agent for Staged activation, with the behavior of each of
+// its stages.
+type Stager interface {
+	Agent
+	Stage(ctx context.Context, stage int) error
+}
+
+// chunk returns the range of the n agents that thread id of threads steps,
+// the same contiguous split life uses for its cells
+func chunk(n, threads, id int) (int, int) {
+	size := n / threads
+	if n%threads != 0 {
+		size += 1
+	}
+	lo, hi := size*id, size*(id+1)
+	if lo > n {
+		lo = n
+	}
+	if hi > n {
+		hi = n
+	}
+	return lo, hi
+}
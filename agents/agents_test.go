@@ -0,0 +1,282 @@
+package agents
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+	"testing"
+
+	"github.com/dacb/goabe/goabetest"
+	"github.com/dacb/goabe/plugins"
+)
+
+// start runs hooks as a plugin in the harness
+func start(t *testing.T, threads int, hooks []plugins.Hook) *goabetest.Harness {
+	noop := func(context.Context) error { return nil }
+	return goabetest.Start(t, plugins.Plugin{
+		Init:        noop,
+		Name:        func() string { return "agents" },
+		Version:     func() (int, int, int) { return 0, 1, 0 },
+		Description: func() string { return "agents test" },
+		GetHooks:    func() []plugins.Hook { return hooks },
+		PreRun:      noop,
+		PostRun:     noop,
+	}, goabetest.Threads(threads), goabetest.SubSteps(3))
+}
+
+// cell is an agent on a ring whose next value is the sum of its
+// neighbors' values
+type cell struct {
+	id          ID
+	ring        *Population[*cell]
+	value, next int
+}
+
+func (c *cell) ID() ID { return c.id }
+
+func (c *cell) Step(ctx context.Context) error {
+	n := ID(c.ring.Len())
+	left, _ := c.ring.Get((c.id+n-2)%n + 1)
+	right, _ := c.ring.Get(c.id%n + 1)
+	c.next = (left.value + right.value) % 1000
+	return nil
+}
+
+func (c *cell) Commit() { c.value = c.next }
+
+func ring(n int) *Population[*cell] {
+	p := NewPopulation[*cell]()
+	for i := 0; i < n; i++ {
+		p.Add(&cell{id: p.NewID(), ring: p, value: i})
+	}
+	p.Apply()
+	return p
+}
+
+func values(p *Population[*cell]) string {
+	var values []int
+	for _, c := range p.Agents() {
+		values = append(values, c.value)
+	}
+	return fmt.Sprint(values)
+}
+
+func TestSimultaneousDoesNotDependOnThreads(t *testing.T) {
+	var reference string
+	for _, threads := range []int{1, 2, 3, 8} {
+		p := ring(13)
+		h := start(t, threads, Simultaneous[*cell]{Population: p}.Hooks())
+		h.Run(5)
+		if reference == "" {
+			reference = values(p)
+		} else if values(p) != reference {
+			t.Errorf("%d threads gave %s, 1 thread %s", threads, values(p), reference)
+		}
+		h.Finish()
+	}
+
+	// one step by hand
+	p := ring(4)
+	h := start(t, 2, Simultaneous[*cell]{Population: p}.Hooks())
+	h.Step()
+	if values(p) != "[4 2 4 2]" {
+		t.Errorf("one step gave %s, want [4 2 4 2]", values(p))
+	}
+}
+
+// mover is an agent that records the order it stepped in and may remove
+// another agent
+type mover struct {
+	id     ID
+	order  *[]ID
+	victim ID
+	p      *Population[*mover]
+	stage  [2]*atomic.Int64
+}
+
+func (m *mover) ID() ID { return m.id }
+
+func (m *mover) Step(ctx context.Context) error {
+	*m.order = append(*m.order, m.id)
+	if m.victim != 0 {
+		m.p.Remove(m.victim)
+	}
+	return nil
+}
+
+func (m *mover) Stage(ctx context.Context, stage int) error {
+	if stage == 1 && m.stage[0].Load() != int64(m.p.Len()) {
+		return fmt.Errorf("stage 1 began before every agent finished stage 0")
+	}
+	m.stage[stage].Add(1)
+	return nil
+}
+
+func movers(n int, order *[]ID) *Population[*mover] {
+	p := NewPopulation[*mover]()
+	stage := [2]*atomic.Int64{new(atomic.Int64), new(atomic.Int64)}
+	for i := 0; i < n; i++ {
+		p.Add(&mover{id: p.NewID(), order: order, p: p, stage: stage})
+	}
+	p.Apply()
+	return p
+}
+
+func TestRandomSequentialIsRepeatable(t *testing.T) {
+	orders := make([][]ID, 2)
+	for i := range orders {
+		p := movers(10, &orders[i])
+		h := start(t, 4, RandomSequential[*mover]{Population: p, SubStep: 1, Rand: rand.New(rand.NewSource(3))}.Hooks())
+		h.Run(2)
+		h.Finish()
+	}
+	if len(orders[0]) != 20 || fmt.Sprint(orders[0]) != fmt.Sprint(orders[1]) {
+		t.Errorf("the orders were %v and %v", orders[0], orders[1])
+	}
+	if fmt.Sprint(orders[0][:10]) == fmt.Sprint(orders[0][10:]) {
+		t.Error("the order did not change between steps")
+	}
+}
+
+func TestRandomSequentialSkipsRemoved(t *testing.T) {
+	var order []ID
+	p := movers(2, &order)
+	first, _ := p.Get(1)
+	second, _ := p.Get(2)
+	first.victim, second.victim = 2, 1
+	h := start(t, 1, RandomSequential[*mover]{Population: p, Rand: rand.New(rand.NewSource(1))}.Hooks())
+	h.Step()
+	if len(order) != 1 || p.Len() != 1 {
+		t.Errorf("stepped %v and kept %d agents, want one of each", order, p.Len())
+	}
+}
+
+func TestStagedFinishesEachStageFirst(t *testing.T) {
+	var order []ID
+	p := movers(50, &order)
+	h := start(t, 4, Staged[*mover]{Population: p, Stages: 2}.Hooks())
+	h.Step()
+	agent, _ := p.Get(1)
+	if agent.stage[0].Load() != 50 || agent.stage[1].Load() != 50 {
+		t.Errorf("ran stages %d and %d times, want 50", agent.stage[0].Load(), agent.stage[1].Load())
+	}
+}
+
+func TestPopulationChangesAtApply(t *testing.T) {
+	p := ring(3)
+	p.Remove(2)
+	p.Add(&cell{id: p.NewID(), ring: p})
+	if p.Len() != 3 {
+		t.Errorf("the population changed before Apply")
+	}
+	p.Apply()
+	var ids []ID
+	for _, c := range p.Agents() {
+		ids = append(ids, c.ID())
+	}
+	if fmt.Sprint(ids) != "[1 3 4]" {
+		t.Errorf("the population is %v, want [1 3 4]", ids)
+	}
+}
+
+func TestPopulationHookAppliesOutsideASchedule(t *testing.T) {
+	p := ring(12)
+	var seen []int
+	hooks := []plugins.Hook{
+		{
+			SubStep: 0,
+			Thread: func(ctx context.Context, id int, name string) error {
+				// each thread kills the first agent of its share
+				if chunk := p.Chunk(id, 3); len(chunk) > 0 {
+					p.Death(id, chunk[0].ID())
+				}
+				return nil
+			},
+		},
+		p.Hook(0),
+		{
+			SubStep: 1,
+			Core: func(ctx context.Context) error {
+				seen = append(seen, p.Len())
+				return nil
+			},
+		},
+	}
+	h := start(t, 3, hooks)
+	h.Run(2)
+	h.Finish()
+	if fmt.Sprint(seen) != "[9 6]" {
+		t.Errorf("the next substep saw %v agents, want [9 6]", seen)
+	}
+}
+
+// breeder is an agent that has two offspring at every third step of its
+// life and dies at its fifth
+type breeder struct {
+	id, parent ID
+	age        int
+	p          *Population[*breeder]
+}
+
+func (b *breeder) ID() ID { return b.id }
+
+func (b *breeder) Step(ctx context.Context) error { return nil }
+
+func (b *breeder) Stage(ctx context.Context, stage int) error {
+	b.age += 1
+	thread := Thread(ctx)
+	if b.age%3 == 0 {
+		for i := 0; i < 2; i++ {
+			b.p.Birth(thread, b.id, func(id ID) *breeder {
+				return &breeder{id: id, parent: b.id, p: b.p}
+			})
+		}
+	}
+	if b.age == 5 {
+		b.p.Death(thread, b.id)
+	}
+	return nil
+}
+
+func TestBirthsAndDeathsDoNotDependOnThreads(t *testing.T) {
+	var reference string
+	for _, threads := range []int{1, 2, 3, 7} {
+		p := NewPopulation[*breeder]()
+		for i := 0; i < 5; i++ {
+			age := i
+			p.Birth(Core, 0, func(id ID) *breeder { return &breeder{id: id, age: age, p: p} })
+		}
+		p.Apply()
+		h := start(t, threads, Staged[*breeder]{Population: p, Stages: 1}.Hooks())
+		h.Run(8)
+		h.Finish()
+
+		var family []string
+		for _, b := range p.Agents() {
+			family = append(family, fmt.Sprintf("%d<%d", b.id, b.parent))
+		}
+		if p.Len() < 10 {
+			t.Fatalf("the population is %v", family)
+		}
+		if reference == "" {
+			reference = fmt.Sprint(family)
+		} else if fmt.Sprint(family) != reference {
+			t.Errorf("%d threads gave %v, 1 thread %s", threads, family, reference)
+		}
+	}
+}
+
+func TestBirthConstructorMayUseThePopulation(t *testing.T) {
+	p := NewPopulation[*breeder]()
+	var reserved ID
+	p.Birth(Core, 0, func(id ID) *breeder {
+		// e.g., an ID for something the newborn owns
+		reserved = p.NewID()
+		return &breeder{id: id, p: p}
+	})
+	p.Apply()
+	if p.Len() != 1 || reserved != 2 {
+		t.Errorf("%d agents and reserved ID %d, want 1 and 2", p.Len(), reserved)
+	}
+}
@@ -0,0 +1,244 @@
+package agents
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/dacb/goabe/plugins"
+)
+
+// Core is the thread to give Birth and Death from core hooks, Init and
+// PreRun.
+const Core = -1
+
+// Population is a set of agents kept in order of their IDs.  The agents
+// are read by every thread during a substep.  Births and deaths are queued
+// in a buffer for each thread, so thread hooks can change the population
+// without contending for it, and take effect when Apply is called at the
+// barrier.  The activation schedules do so in their core hooks, but the
+// engine does not apply populations itself: a plugin that changes one from
+// its own hooks returns the population's Hook from GetHooks, or calls Apply
+// from one of its core hooks.
+//
+// The agents born in a substep are given their IDs at the barrier in
+// order of their parents' IDs and then the order each parent gave birth
+// to them, so the IDs are the same whatever the number of threads.
+type Population[A Agent] struct {
+	agents []A
+	index  map[ID]int
+	next   ID // the last ID given out
+
+	mu      sync.RWMutex
+	buffers []*buffer[A] // one per thread, the first for the core
+	added   []A          // by Add, with their own IDs
+}
+
+// buffer holds the births and deaths queued from one thread
+type buffer[A Agent] struct {
+	mu     sync.Mutex
+	births []birth[A]
+	deaths map[ID]bool
+}
+
+// birth is an agent to create at the barrier
+type birth[A Agent] struct {
+	parent   ID
+	newAgent func(ID) A
+}
+
+// NewPopulation creates an empty population.
+func NewPopulation[A Agent]() *Population[A] {
+	return &Population[A]{index: map[ID]int{}}
+}
+
+// NewID returns an unused ID for an agent to Add.  It is for the core; the
+// IDs of agents born in thread hooks are given out by Birth.
+func (p *Population[A]) NewID() ID {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.next += 1
+	return p.next
+}
+
+// Add queues an agent with its own ID, e.g., from NewID, to join the
+// population at the next Apply.
+func (p *Population[A]) Add(agent A) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.added = append(p.added, agent)
+	if agent.ID() > p.next {
+		p.next = agent.ID()
+	}
+}
+
+// Remove queues the agent with the ID to leave the population at the next
+// Apply; it is Death from the core.
+func (p *Population[A]) Remove(id ID) {
+	p.Death(Core, id)
+}
+
+// Birth queues a new agent, the offspring of parent, to be made with its
+// ID by newAgent and join the population at the next Apply.  thread is the
+// id of the thread hook calling it, e.g., Thread(ctx) in an agent's Step,
+// or Core.  Agents with no parent, 0, should be born from the core, as the
+// order of those born on different threads depends on the threads.
+// newAgent is called by Apply, which does not hold the population's lock
+// then, so it may call NewID, Add or Birth for the next Apply.
+func (p *Population[A]) Birth(thread int, parent ID, newAgent func(ID) A) {
+	b := p.buffer(thread)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.births = append(b.births, birth[A]{parent: parent, newAgent: newAgent})
+}
+
+// Death queues the agent with the ID to leave the population at the next
+// Apply.  thread is as for Birth.
+func (p *Population[A]) Death(thread int, id ID) {
+	b := p.buffer(thread)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.deaths == nil {
+		b.deaths = map[ID]bool{}
+	}
+	b.deaths[id] = true
+}
+
+// buffer returns the buffer of a thread, adding buffers as needed
+func (p *Population[A]) buffer(thread int) *buffer[A] {
+	i := thread + 1
+	p.mu.RLock()
+	if i < len(p.buffers) {
+		b := p.buffers[i]
+		p.mu.RUnlock()
+		return b
+	}
+	p.mu.RUnlock()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for len(p.buffers) <= i {
+		p.buffers = append(p.buffers, new(buffer[A]))
+	}
+	return p.buffers[i]
+}
+
+// Apply merges the births and deaths queued since the last Apply: the
+// dead leave the population and the newborn, given their IDs, join it.  It
+// must not be called while hooks read the population.
+func (p *Population[A]) Apply() {
+	p.mu.Lock()
+	dead := map[ID]bool{}
+	var births []birth[A]
+	for _, b := range p.buffers {
+		for id := range b.deaths {
+			dead[id] = true
+		}
+		births = append(births, b.births...)
+		b.births, b.deaths = nil, nil
+	}
+	if len(dead) == 0 && len(births) == 0 && len(p.added) == 0 {
+		p.mu.Unlock()
+		return
+	}
+	// each parent's births are all in the buffer of the thread that
+	// stepped it, in order, so a stable sort by parent does not depend on
+	// how the agents were split between the threads
+	sort.SliceStable(births, func(i, j int) bool { return births[i].parent < births[j].parent })
+
+	agents := p.agents[:0:0]
+	for _, agent := range p.agents {
+		if !dead[agent.ID()] {
+			agents = append(agents, agent)
+		}
+	}
+	for _, agent := range p.added {
+		if !dead[agent.ID()] {
+			agents = append(agents, agent)
+		}
+	}
+	sort.SliceStable(agents, func(i, j int) bool { return agents[i].ID() < agents[j].ID() })
+	// the newborn have the highest IDs, so they stay in order at the end
+	ids := make([]ID, len(births))
+	for i := range births {
+		p.next += 1
+		ids[i] = p.next
+	}
+	p.added = nil
+	p.mu.Unlock()
+
+	// the constructors may use the population, e.g., NewID
+	for i, b := range births {
+		agents = append(agents, b.newAgent(ids[i]))
+	}
+	p.agents = agents
+	p.index = make(map[ID]int, len(agents))
+	for i, agent := range agents {
+		p.index[agent.ID()] = i
+	}
+}
+
+// Hook returns a core hook that applies the population's changes at the
+// barrier of subStep, for a plugin that changes the population outside of
+// an activation schedule.  It should be the last hook of the substep to
+// change the population.
+func (p *Population[A]) Hook(subStep int) plugins.Hook {
+	return plugins.Hook{
+		SubStep: subStep,
+		Core: func(ctx context.Context) error {
+			p.Apply()
+			return nil
+		},
+		Description: "apply the population's births and deaths",
+	}
+}
+
+// Agents returns the agents in order of their IDs.  The slice must not be
+// modified.
+func (p *Population[A]) Agents() []A {
+	return p.agents
+}
+
+// Len returns the number of agents.
+func (p *Population[A]) Len() int {
+	return len(p.agents)
+}
+
+// Get returns the agent with the ID.
+func (p *Population[A]) Get(id ID) (A, bool) {
+	i, ok := p.index[id]
+	if !ok {
+		var none A
+		return none, false
+	}
+	return p.agents[i], true
+}
+
+// Chunk returns the agents thread id of threads works on.
+func (p *Population[A]) Chunk(id, threads int) []A {
+	lo, hi := chunk(len(p.agents), threads, id)
+	return p.agents[lo:hi]
+}
+
+// dying reports whether the agent with the ID is queued to leave
+func (p *Population[A]) dying(id ID) bool {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	for _, b := range p.buffers {
+		b.mu.Lock()
+		dead := b.deaths[id]
+		b.mu.Unlock()
+		if dead {
+			return true
+		}
+	}
+	return false
+}
+
+// Thread returns the id of the thread an activation schedule is stepping
+// agents on, for Birth and Death, or Core on the core.
+func Thread(ctx context.Context) int {
+	if id, ok := ctx.Value("thread").(int); ok {
+		return id
+	}
+	return Core
+}
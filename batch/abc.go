@@ -0,0 +1,325 @@
+package batch
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/dacb/goabe/metrics"
+
+	"github.com/spf13/viper"
+)
+
+// Calibration fits parameters to observations with approximate Bayesian
+// computation, read from a spec file, e.g.,
+//
+//	method: smc              # or rejection
+//	steps: 100
+//	seed: 1
+//	output: life.alive_cells # the metric compared to the observations
+//	observed: observed.csv
+//	distance: rmse           # euclidean, rmse, mae or max
+//	particles: 100           # posterior samples
+//	generations: 5           # smc
+//	quantile: 0.5            # smc tolerance schedule
+//	priors:
+//	  life.x_size: {min: 8, max: 64, type: int}
+//
+// The priors are uniform over their ranges.  Rejection draws samples
+// parameter sets from the priors and accepts those within tolerance of the
+// observations, or the particles closest if no tolerance is given.  SMC
+// (Beaumont et al. 2009) starts from particles draws from the priors and
+// then, each generation, perturbs the accepted particles of the previous
+// generation with a Gaussian kernel until particles are within a tolerance
+// that is the quantile of the previous generation's distances.
+type Calibration struct {
+	Method      string
+	Steps       int64
+	Parallel    int
+	OutputDir   string
+	Seed        int64
+	Output      string
+	Observed    string
+	Distance    string
+	Particles   int
+	Samples     int     // rejection: parameter sets drawn from the priors
+	Tolerance   float64 // rejection: the largest accepted distance, 0 for none
+	Generations int     // smc: generations after the first
+	Quantile    float64 // smc: of the distances, for the next tolerance
+	MaxRuns     int     // smc: runs allowed for a generation
+	Priors      []Parameter
+}
+
+// Particle is an accepted parameter set.
+type Particle struct {
+	Values   []float64 // the value of each prior
+	Weight   float64   // normalized importance weight
+	Distance float64
+}
+
+// Simulate runs the model for each parameter set, numbered from first
+// within the generation, and returns their distances from the observations.
+type Simulate func(generation, first int, values [][]float64) ([]float64, error)
+
+// ReadCalibration reads a calibration spec in any format viper supports.
+func ReadCalibration(filename string) (*Calibration, error) {
+	v := viper.New()
+	v.SetConfigFile(filename)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("unable to read calibration spec %s: %w", filename, err)
+	}
+	v.SetDefault("method", "smc")
+	v.SetDefault("seed", 1)
+	v.SetDefault("distance", "euclidean")
+	v.SetDefault("particles", 100)
+	v.SetDefault("samples", 1000)
+	v.SetDefault("generations", 5)
+	v.SetDefault("quantile", 0.5)
+	c := &Calibration{
+		Method:      v.GetString("method"),
+		Steps:       v.GetInt64("steps"),
+		Parallel:    v.GetInt("parallel"),
+		OutputDir:   v.GetString("output_dir"),
+		Seed:        v.GetInt64("seed"),
+		Output:      v.GetString("output"),
+		Observed:    v.GetString("observed"),
+		Distance:    v.GetString("distance"),
+		Particles:   v.GetInt("particles"),
+		Samples:     v.GetInt("samples"),
+		Tolerance:   v.GetFloat64("tolerance"),
+		Generations: v.GetInt("generations"),
+		Quantile:    v.GetFloat64("quantile"),
+		MaxRuns:     v.GetInt("max_runs"),
+	}
+	if c.Method != "rejection" && c.Method != "smc" {
+		return nil, fmt.Errorf("%s: unknown method '%s', expecting rejection or smc", filename, c.Method)
+	}
+	if c.Output == "" || c.Observed == "" {
+		return nil, fmt.Errorf("%s: the output metric and the observed file are required", filename)
+	}
+	if c.Particles < 2 {
+		return nil, fmt.Errorf("%s: at least 2 particles are needed", filename)
+	}
+	if c.Method == "rejection" && c.Samples < c.Particles {
+		return nil, fmt.Errorf("%s: samples must be at least particles", filename)
+	}
+	if c.Quantile <= 0 || c.Quantile >= 1 {
+		return nil, fmt.Errorf("%s: quantile must be between 0 and 1", filename)
+	}
+	if c.MaxRuns == 0 {
+		c.MaxRuns = 100 * c.Particles
+	}
+	priors, err := readParameters(v, "priors")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	c.Priors = priors
+	return c, nil
+}
+
+// Params returns a parameter set as configuration values.
+func (c *Calibration) Params(values []float64) map[string]any {
+	params := map[string]any{}
+	for i, prior := range c.Priors {
+		params[prior.Key] = prior.Param(values[i])
+	}
+	return params
+}
+
+// Run calibrates the parameters and returns the final posterior sample.
+// done, if not nil, is called with the accepted particles of each
+// generation, its tolerance and the number of runs it took.
+func (c *Calibration) Run(simulate Simulate, done func(generation int, tolerance float64, particles []Particle, runs int)) ([]Particle, error) {
+	rng := rand.New(rand.NewSource(c.Seed))
+	if c.Method == "rejection" {
+		particles, tolerance, err := c.rejection(rng, simulate)
+		if err == nil && done != nil {
+			done(0, tolerance, particles, c.Samples)
+		}
+		return particles, err
+	}
+
+	// the first generation accepts all of the draws from the priors
+	particles, err := c.fromPriors(rng, simulate, c.Particles, math.Inf(1))
+	if err != nil {
+		return nil, err
+	}
+	if done != nil {
+		done(0, math.Inf(1), particles, c.Particles)
+	}
+	for generation := 1; generation <= c.Generations; generation++ {
+		distances := make([]float64, len(particles))
+		for i := range particles {
+			distances[i] = particles[i].Distance
+		}
+		sort.Float64s(distances)
+		tolerance := metrics.Quantile(distances, c.Quantile)
+
+		next, runs, err := c.generation(rng, simulate, generation, particles, tolerance)
+		if err != nil {
+			return particles, err
+		}
+		particles = next
+		if done != nil {
+			done(generation, tolerance, particles, runs)
+		}
+	}
+	return particles, nil
+}
+
+func (c *Calibration) rejection(rng *rand.Rand, simulate Simulate) ([]Particle, float64, error) {
+	tolerance := c.Tolerance
+	if tolerance <= 0 {
+		tolerance = math.Inf(1)
+	}
+	particles, err := c.fromPriors(rng, simulate, c.Samples, tolerance)
+	if err != nil {
+		return nil, 0, err
+	}
+	if c.Tolerance <= 0 {
+		// keep the closest
+		sort.SliceStable(particles, func(i, j int) bool { return particles[i].Distance < particles[j].Distance })
+		particles = particles[:c.Particles]
+		tolerance = particles[len(particles)-1].Distance
+	}
+	if len(particles) == 0 {
+		return nil, 0, fmt.Errorf("none of the %d samples are within the tolerance %v", c.Samples, c.Tolerance)
+	}
+	for i := range particles {
+		particles[i].Weight = 1 / float64(len(particles))
+	}
+	return particles, tolerance, nil
+}
+
+// fromPriors simulates n draws from the priors and returns those within
+// tolerance with equal weights
+func (c *Calibration) fromPriors(rng *rand.Rand, simulate Simulate, n int, tolerance float64) ([]Particle, error) {
+	values := make([][]float64, n)
+	for i := range values {
+		values[i] = make([]float64, len(c.Priors))
+		for j, prior := range c.Priors {
+			values[i][j] = prior.Sample(rng.Float64())
+		}
+	}
+	distances, err := simulate(0, 0, values)
+	if err != nil {
+		return nil, err
+	}
+	var particles []Particle
+	for i := range values {
+		if distances[i] <= tolerance {
+			particles = append(particles, Particle{Values: values[i], Distance: distances[i]})
+		}
+	}
+	for i := range particles {
+		particles[i].Weight = 1 / float64(len(particles))
+	}
+	return particles, nil
+}
+
+// generation perturbs the previous particles until enough are within
+// tolerance
+func (c *Calibration) generation(rng *rand.Rand, simulate Simulate, generation int, previous []Particle, tolerance float64) ([]Particle, int, error) {
+	// the kernel's variance is twice the weighted variance of the previous
+	// particles (Beaumont et al. 2009), component wise
+	sigma := make([]float64, len(c.Priors))
+	for j := range c.Priors {
+		mean, variance := 0.0, 0.0
+		for _, p := range previous {
+			mean += p.Weight * p.Values[j]
+		}
+		for _, p := range previous {
+			variance += p.Weight * (p.Values[j] - mean) * (p.Values[j] - mean)
+		}
+		sigma[j] = math.Sqrt(2 * variance)
+	}
+
+	var particles []Particle
+	runs := 0
+	for len(particles) < c.Particles {
+		if runs >= c.MaxRuns {
+			return nil, runs, fmt.Errorf("generation %d accepted %d of %d particles in %d runs at tolerance %v",
+				generation, len(particles), c.Particles, runs, tolerance)
+		}
+		n := c.Particles - len(particles)
+		if n < c.Parallel {
+			n = c.Parallel
+		}
+		values := make([][]float64, n)
+		for i := range values {
+			values[i] = c.perturb(rng, previous, sigma)
+		}
+		distances, err := simulate(generation, runs, values)
+		if err != nil {
+			return nil, runs, err
+		}
+		runs += n
+		for i := range values {
+			if distances[i] <= tolerance && len(particles) < c.Particles {
+				particles = append(particles, Particle{
+					Values:   values[i],
+					Weight:   1 / kernelDensity(values[i], previous, sigma),
+					Distance: distances[i],
+				})
+			}
+		}
+	}
+	sum := 0.0
+	for _, p := range particles {
+		sum += p.Weight
+	}
+	for i := range particles {
+		particles[i].Weight /= sum
+	}
+	return particles, runs, nil
+}
+
+// perturb draws a previous particle by weight and moves it with the kernel
+// until it is inside the priors
+func (c *Calibration) perturb(rng *rand.Rand, previous []Particle, sigma []float64) []float64 {
+	for {
+		u := rng.Float64()
+		chosen := previous[len(previous)-1]
+		for _, p := range previous {
+			if u < p.Weight {
+				chosen = p
+				break
+			}
+			u -= p.Weight
+		}
+		values := make([]float64, len(c.Priors))
+		inside := true
+		for j, prior := range c.Priors {
+			values[j] = chosen.Values[j] + sigma[j]*rng.NormFloat64()
+			if prior.Integer {
+				values[j] = math.Round(values[j])
+			}
+			inside = inside && prior.Contains(values[j])
+		}
+		if inside {
+			return values
+		}
+	}
+}
+
+// kernelDensity is the density of values under the mixture of the kernels
+// around the previous particles, up to a constant
+func kernelDensity(values []float64, previous []Particle, sigma []float64) float64 {
+	density := 0.0
+	for _, p := range previous {
+		k := p.Weight
+		for j := range values {
+			if sigma[j] == 0 {
+				if values[j] != p.Values[j] {
+					k = 0
+				}
+				continue
+			}
+			z := (values[j] - p.Values[j]) / sigma[j]
+			k *= math.Exp(-z * z / 2)
+		}
+		density += k
+	}
+	return density
+}
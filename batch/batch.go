@@ -0,0 +1,220 @@
+// Package batch runs many simulations of the configured model, each as a
+// separate goabe process with its own configuration and output directory,
+// over a pool of worker processes.  It is the basis of the sweep command.
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Run is one simulation in a batch.
+type Run struct {
+	ID     string         // identifier, also the name of the output directory
+	Params map[string]any // configuration keys set for this run
+	Dir    string         // output directory, set by the Runner
+}
+
+// Result is the outcome of a Run.
+type Result struct {
+	Run
+	Err      error
+	Duration time.Duration
+	Files    []string // files in the output directory after the run
+}
+
+// Runner runs batches of simulations.
+type Runner struct {
+	Executable string         // the goabe binary, normally os.Executable()
+	Base       map[string]any // the effective configuration shared by all runs
+	InputKeys  []string       // keys naming input files, made absolute
+	OutputKeys []string       // keys naming output files, which must be relative
+	OutputDir  string         // each run gets a subdirectory here
+	Steps      int64          // steps for each run
+	Threads    int            // threads for each run
+	Parallel   int            // number of runs at once
+	Args       []string       // extra arguments for each run command
+	// Done, if not nil, is called as each run finishes, from its own goroutine
+	Done func(Result)
+}
+
+// ConfigName is the name of the configuration file written for each run.
+const ConfigName = "goabe.json"
+
+// Execute runs all of the runs and returns their results in the same order.
+// A failed run does not stop the others; its error is in its result.
+func (r *Runner) Execute(ctx context.Context, runs []Run) []Result {
+	results := make([]Result, len(runs))
+	parallel := r.Parallel
+	if parallel < 1 {
+		parallel = 1
+	}
+	slots := make(chan struct{}, parallel)
+	wg := new(sync.WaitGroup)
+	for i := range runs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			results[i] = r.execute(ctx, runs[i])
+			if r.Done != nil {
+				r.Done(results[i])
+			}
+		}(i)
+	}
+	wg.Wait()
+	return results
+}
+
+func (r *Runner) execute(ctx context.Context, run Run) (result Result) {
+	run.Dir = filepath.Join(r.OutputDir, run.ID)
+	result = Result{Run: run}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	if err := os.MkdirAll(run.Dir, 0777); err != nil {
+		result.Err = err
+		return result
+	}
+	config, err := r.Config(run)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	if err := os.WriteFile(filepath.Join(run.Dir, ConfigName), data, 0666); err != nil {
+		result.Err = err
+		return result
+	}
+
+	output, err := os.Create(filepath.Join(run.Dir, "output.txt"))
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer output.Close()
+	args := []string{"--config", ConfigName, "--threads", strconv.Itoa(r.Threads)}
+	args = append(args, r.Args...)
+	args = append(args, "run", "--steps", strconv.FormatInt(r.Steps, 10))
+	cmd := exec.CommandContext(ctx, r.Executable, args...)
+	// the run's relative output files land in its own directory
+	cmd.Dir = run.Dir
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		result.Err = fmt.Errorf("%s: %w, see %s", run.ID, err, output.Name())
+	}
+
+	entries, err := os.ReadDir(run.Dir)
+	if err == nil {
+		for _, entry := range entries {
+			result.Files = append(result.Files, entry.Name())
+		}
+	}
+	return result
+}
+
+// Config returns the configuration of a run: the base configuration with
+// the run's id and parameters set and relative input file names made
+// absolute, since the run executes in its own directory.  An absolute
+// output file name is an error since every run would write the same file.
+func (r *Runner) Config(run Run) (map[string]any, error) {
+	config := copySettings(r.Base)
+	config["run_id"] = run.ID
+	// the run's directory already separates its logs from the others
+	config["log_run_dir"] = false
+	for key, value := range run.Params {
+		setKey(config, key, value)
+	}
+	for _, key := range r.InputKeys {
+		value, ok := getKey(config, key).(string)
+		if !ok || value == "" || filepath.IsAbs(value) {
+			continue
+		}
+		abs, err := filepath.Abs(value)
+		if err != nil {
+			return nil, err
+		}
+		setKey(config, key, abs)
+	}
+	for _, key := range r.OutputKeys {
+		if value, ok := getKey(config, key).(string); ok && filepath.IsAbs(value) {
+			return nil, fmt.Errorf("%s '%s' is an absolute path, so every run would write it; use a path relative to the run directory", key, value)
+		}
+	}
+	return config, nil
+}
+
+func copySettings(src map[string]any) map[string]any {
+	dst := make(map[string]any, len(src))
+	for key, value := range src {
+		if nested, ok := value.(map[string]any); ok {
+			value = copySettings(nested)
+		}
+		dst[key] = value
+	}
+	return dst
+}
+
+// setKey sets a dotted key such as life.x_size in nested settings
+func setKey(settings map[string]any, key string, value any) {
+	path := strings.Split(key, ".")
+	for _, name := range path[:len(path)-1] {
+		nested, ok := settings[name].(map[string]any)
+		if !ok {
+			nested = map[string]any{}
+			settings[name] = nested
+		}
+		settings = nested
+	}
+	settings[path[len(path)-1]] = value
+}
+
+// getKey returns the value of a dotted key in nested settings, or nil
+func getKey(settings map[string]any, key string) any {
+	path := strings.Split(key, ".")
+	for _, name := range path[:len(path)-1] {
+		nested, ok := settings[name].(map[string]any)
+		if !ok {
+			return nil
+		}
+		settings = nested
+	}
+	return settings[path[len(path)-1]]
+}
+
+// ParamKeys returns the sorted union of the parameter keys of the runs.
+func ParamKeys(runs []Run) []string {
+	seen := map[string]bool{}
+	var keys []string
+	for _, run := range runs {
+		for key := range run.Params {
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// GetKey returns the value of a dotted key such as life.x_size from the
+// configuration of a run, or nil if it is not set.
+func GetKey(config map[string]any, key string) any {
+	return getKey(config, key)
+}
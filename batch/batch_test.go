@@ -0,0 +1,20 @@
+package batch
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestConfigRejectsAbsoluteOutputs(t *testing.T) {
+	runner := &Runner{
+		Base:       map[string]any{"log_file": "goabe.log.json", "life": map[string]any{"out_filename": ""}},
+		OutputKeys: []string{"life.out_filename", "log_file"},
+	}
+	if _, err := runner.Config(Run{ID: "run_1"}); err != nil {
+		t.Errorf("relative and empty outputs were rejected: %v", err)
+	}
+	absolute := filepath.Join(t.TempDir(), "goabe.log.json")
+	if _, err := runner.Config(Run{ID: "run_1", Params: map[string]any{"log_file": absolute}}); err == nil {
+		t.Error("an absolute log_file was accepted")
+	}
+}
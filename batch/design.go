@@ -0,0 +1,263 @@
+package batch
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// Design is a sampling design for a global sensitivity analysis, read from
+// a spec file, e.g.,
+//
+//	sampling: lhs            # or saltelli
+//	samples: 64
+//	seed: 1
+//	steps: 100
+//	statistic: final         # or mean, of each output over the steps
+//	outputs: [life.alive_cells]
+//	parameters:
+//	  life.x_size: {min: 8, max: 64, type: int}
+//	  life.y_size: {min: 8, max: 64, type: int}
+//
+// Both samplings use Saltelli's scheme of two independent base matrices A
+// and B of samples rows and the matrices AB_i, which are A with the i-th
+// column taken from B, so that first order and total Sobol indices can be
+// estimated from samples*(parameters+2) runs.  The saltelli sampling takes
+// A and B from the two halves of a Sobol sequence of twice as many
+// dimensions as parameters, as Saltelli does, which covers the ranges
+// more evenly than random samples, best with a power of 2 samples; it
+// does not depend on the seed.  The lhs sampling draws each of A and B as a
+// Latin hypercube, which spreads the samples over the range of every
+// parameter.
+type Design struct {
+	Sampling   string
+	Samples    int
+	Seed       int64
+	Steps      int64
+	Parallel   int
+	OutputDir  string
+	Statistic  string
+	Outputs    []string
+	Parameters []Parameter // sorted by key
+}
+
+// Parameter is the range a parameter is sampled from.
+type Parameter struct {
+	Key      string
+	Min, Max float64
+	Integer  bool // sample whole numbers from Min to Max inclusive
+}
+
+// ReadDesign reads a sensitivity spec in any format viper supports.
+func ReadDesign(filename string) (*Design, error) {
+	v := viper.New()
+	v.SetConfigFile(filename)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("unable to read sensitivity spec %s: %w", filename, err)
+	}
+	v.SetDefault("sampling", "lhs")
+	v.SetDefault("seed", 1)
+	v.SetDefault("statistic", "final")
+	design := &Design{
+		Sampling:  v.GetString("sampling"),
+		Samples:   v.GetInt("samples"),
+		Seed:      v.GetInt64("seed"),
+		Steps:     v.GetInt64("steps"),
+		Parallel:  v.GetInt("parallel"),
+		OutputDir: v.GetString("output_dir"),
+		Statistic: v.GetString("statistic"),
+		Outputs:   v.GetStringSlice("outputs"),
+	}
+	if design.Sampling != "lhs" && design.Sampling != "saltelli" {
+		return nil, fmt.Errorf("%s: unknown sampling '%s', expecting lhs or saltelli", filename, design.Sampling)
+	}
+	if design.Statistic != "final" && design.Statistic != "mean" {
+		return nil, fmt.Errorf("%s: unknown statistic '%s', expecting final or mean", filename, design.Statistic)
+	}
+	if len(design.Outputs) == 0 {
+		return nil, fmt.Errorf("%s: no outputs to analyze", filename)
+	}
+	parameters, err := readParameters(v, "parameters")
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	design.Parameters = parameters
+	return design, nil
+}
+
+// readParameters reads the ranges of the parameters in the map at key,
+// sorted by parameter key.
+func readParameters(v *viper.Viper, key string) ([]Parameter, error) {
+	// parameters are read from the raw map since their keys contain dots
+	specs, err := cast.ToStringMapE(v.Get(key))
+	if err != nil || len(specs) == 0 {
+		return nil, fmt.Errorf("no %s to sample", key)
+	}
+	var parameters []Parameter
+	for name, spec := range flattenDesign("", specs) {
+		parameter, err := parameterRange(name, spec)
+		if err != nil {
+			return nil, fmt.Errorf("parameter %s: %w", name, err)
+		}
+		parameters = append(parameters, parameter)
+	}
+	sort.Slice(parameters, func(i, j int) bool { return parameters[i].Key < parameters[j].Key })
+	return parameters, nil
+}
+
+// flattenDesign turns nested parameter maps into dotted keys, stopping at
+// the ranges
+func flattenDesign(prefix string, parameters map[string]any) map[string]map[string]any {
+	flat := map[string]map[string]any{}
+	for key, value := range parameters {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		nested, err := cast.ToStringMapE(value)
+		if err != nil {
+			// reported by parameterRange
+			flat[key] = nil
+			continue
+		}
+		_, min := nested["min"]
+		_, max := nested["max"]
+		if min || max {
+			flat[key] = nested
+			continue
+		}
+		for k, v := range flattenDesign(key, nested) {
+			flat[k] = v
+		}
+	}
+	return flat
+}
+
+func parameterRange(key string, spec map[string]any) (Parameter, error) {
+	p := Parameter{Key: key}
+	if spec == nil {
+		return p, fmt.Errorf("expecting a range {min, max}")
+	}
+	var err error
+	if p.Min, err = cast.ToFloat64E(spec["min"]); err != nil {
+		return p, fmt.Errorf("invalid min: %w", err)
+	}
+	if p.Max, err = cast.ToFloat64E(spec["max"]); err != nil {
+		return p, fmt.Errorf("invalid max: %w", err)
+	}
+	switch kind := cast.ToString(spec["type"]); kind {
+	case "", "float":
+	case "int":
+		p.Integer = true
+		if p.Min != math.Trunc(p.Min) || p.Max != math.Trunc(p.Max) {
+			return p, fmt.Errorf("an int range needs whole numbers")
+		}
+	default:
+		return p, fmt.Errorf("unknown type '%s', expecting int or float", kind)
+	}
+	if p.Max <= p.Min {
+		return p, fmt.Errorf("max must be greater than min")
+	}
+	return p, nil
+}
+
+// Value maps u, from 0 up to 1, into the parameter's range.
+func (p Parameter) Value(u float64) any {
+	return p.Param(p.Sample(u))
+}
+
+// Sample maps u, from 0 up to 1, to a number in the parameter's range, a
+// whole number if it is an integer.
+func (p Parameter) Sample(u float64) float64 {
+	if p.Integer {
+		return math.Min(math.Floor(p.Min+u*(p.Max-p.Min+1)), p.Max)
+	}
+	return p.Min + u*(p.Max-p.Min)
+}
+
+// Param returns x as the configuration value of the parameter.
+func (p Parameter) Param(x float64) any {
+	if p.Integer {
+		return int64(math.Round(x))
+	}
+	return x
+}
+
+// Contains reports if x is in the parameter's range.
+func (p Parameter) Contains(x float64) bool {
+	return x >= p.Min && x <= p.Max
+}
+
+// Runs returns the runs of the design: the rows of A, then B, then AB_i for
+// each parameter in order.  The same seed always gives the same runs.
+func (d *Design) Runs() ([]Run, error) {
+	if d.Samples < 2 {
+		return nil, fmt.Errorf("at least 2 samples are needed, not %d", d.Samples)
+	}
+	rng := rand.New(rand.NewSource(d.Seed))
+	dims := len(d.Parameters)
+	var a, b [][]float64
+	if d.Sampling == "saltelli" {
+		if 2*dims > MaxSobolDims {
+			return nil, fmt.Errorf("saltelli sampling supports up to %d parameters, not %d, use lhs", MaxSobolDims/2, dims)
+		}
+		points, err := Sobol(d.Samples, 2*dims)
+		if err != nil {
+			return nil, err
+		}
+		for _, point := range points {
+			a, b = append(a, point[:dims]), append(b, point[dims:])
+		}
+	} else {
+		a, b = LatinHypercube(rng, d.Samples, dims), LatinHypercube(rng, d.Samples, dims)
+	}
+	points := append(append([][]float64{}, a...), b...)
+	for i := 0; i < dims; i++ {
+		for k, row := range a {
+			mixed := append([]float64{}, row...)
+			mixed[i] = b[k][i]
+			points = append(points, mixed)
+		}
+	}
+
+	runs := make([]Run, len(points))
+	width := len(fmt.Sprint(len(points)))
+	for i, point := range points {
+		params := map[string]any{}
+		for j, parameter := range d.Parameters {
+			params[parameter.Key] = parameter.Value(point[j])
+		}
+		runs[i] = Run{ID: fmt.Sprintf("run_%0*d", width, i), Params: params}
+	}
+	return runs, nil
+}
+
+// Split divides the outputs of the runs, in the order of Runs, into those
+// of A, B and each AB_i.
+func (d *Design) Split(outputs []float64) (a, b []float64, ab [][]float64) {
+	n := d.Samples
+	a, b = outputs[:n], outputs[n:2*n]
+	for i := range d.Parameters {
+		ab = append(ab, outputs[(2+i)*n:(3+i)*n])
+	}
+	return a, b, ab
+}
+
+// LatinHypercube returns n points in the unit hypercube of dims dimensions
+// such that each of the n equal intervals of every dimension holds exactly
+// one point.
+func LatinHypercube(rng *rand.Rand, n, dims int) [][]float64 {
+	points := make([][]float64, n)
+	for i := range points {
+		points[i] = make([]float64, dims)
+	}
+	for j := 0; j < dims; j++ {
+		for i, stratum := range rng.Perm(n) {
+			points[i][j] = (float64(stratum) + rng.Float64()) / float64(n)
+		}
+	}
+	return points
+}
@@ -0,0 +1,89 @@
+package batch
+
+import "fmt"
+
+// sobolBits is the number of bits of the numbers in a Sobol sequence
+const sobolBits = 32
+
+// sobolDirections are the primitive polynomials and initial direction
+// numbers of dimensions 2 on of the Sobol sequence, from Joe and Kuo's
+// new-joe-kuo-6.21201: the degree s, the coefficients a and m_1 to m_s.
+// The first dimension is the van der Corput sequence.
+var sobolDirections = []struct {
+	s, a uint
+	m    []uint64
+}{
+	{1, 0, []uint64{1}},
+	{2, 1, []uint64{1, 3}},
+	{3, 1, []uint64{1, 3, 1}},
+	{3, 2, []uint64{1, 1, 1}},
+	{4, 1, []uint64{1, 1, 3, 3}},
+	{4, 4, []uint64{1, 3, 5, 13}},
+	{5, 2, []uint64{1, 1, 5, 5, 17}},
+	{5, 4, []uint64{1, 1, 5, 5, 5}},
+	{5, 7, []uint64{1, 1, 7, 11, 19}},
+	{5, 11, []uint64{1, 1, 5, 1, 1}},
+	{5, 13, []uint64{1, 1, 1, 3, 11}},
+	{5, 14, []uint64{1, 3, 5, 5, 31}},
+	{6, 1, []uint64{1, 3, 3, 9, 7, 49}},
+	{6, 13, []uint64{1, 1, 1, 15, 21, 21}},
+	{6, 16, []uint64{1, 3, 1, 13, 27, 49}},
+	{6, 19, []uint64{1, 1, 1, 15, 7, 5}},
+	{6, 22, []uint64{1, 3, 1, 15, 13, 25}},
+	{6, 25, []uint64{1, 1, 5, 5, 19, 61}},
+	{7, 1, []uint64{1, 3, 7, 11, 23, 15, 103}},
+	{7, 4, []uint64{1, 3, 7, 13, 13, 15, 69}},
+}
+
+// MaxSobolDims is the largest number of dimensions Sobol supports.
+var MaxSobolDims = len(sobolDirections) + 1
+
+// Sobol returns the first n points of the Sobol low-discrepancy sequence
+// in dims dimensions, after the point at the origin, which is skipped.
+// The points fill the unit hypercube more evenly than random ones; the
+// first 2^k points put exactly one point in each of the 2^k equal
+// intervals of every dimension, so n is best a power of 2.
+func Sobol(n, dims int) ([][]float64, error) {
+	if dims < 1 || dims > MaxSobolDims {
+		return nil, fmt.Errorf("the Sobol sequence has 1 to %d dimensions, not %d", MaxSobolDims, dims)
+	}
+	if n < 0 || int64(n) >= 1<<sobolBits {
+		return nil, fmt.Errorf("the Sobol sequence has fewer than 2^%d points, not %d", sobolBits, n)
+	}
+	// the direction numbers of each dimension, scaled to sobolBits bits
+	directions := make([][sobolBits + 1]uint64, dims)
+	for i := 1; i <= sobolBits; i++ {
+		directions[0][i] = 1 << (sobolBits - i)
+	}
+	for j := 1; j < dims; j++ {
+		d := sobolDirections[j-1]
+		v := &directions[j]
+		s := int(d.s)
+		for i := 1; i <= s && i <= sobolBits; i++ {
+			v[i] = d.m[i-1] << (sobolBits - i)
+		}
+		for i := s + 1; i <= sobolBits; i++ {
+			v[i] = v[i-s] ^ (v[i-s] >> s)
+			for k := 1; k < s; k++ {
+				v[i] ^= ((uint64(d.a) >> (s - 1 - k)) & 1) * v[i-k]
+			}
+		}
+	}
+
+	// each point is the last one with the direction numbers of the
+	// lowest zero bit of its index mixed in (Gray code order)
+	points := make([][]float64, n)
+	x := make([]uint64, dims)
+	for i := 0; i < n; i++ {
+		bit := 1
+		for index := uint64(i); index&1 == 1; index >>= 1 {
+			bit += 1
+		}
+		points[i] = make([]float64, dims)
+		for j := range x {
+			x[j] ^= directions[j][bit]
+			points[i][j] = float64(x[j]) / (1 << sobolBits)
+		}
+	}
+	return points, nil
+}
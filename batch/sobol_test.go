@@ -0,0 +1,40 @@
+package batch
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSobolStartsLikeTheReference(t *testing.T) {
+	points, err := Sobol(4, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[[0.5 0.5 0.5] [0.75 0.25 0.25] [0.25 0.75 0.75] [0.375 0.375 0.625]]"
+	if fmt.Sprint(points) != want {
+		t.Errorf("the first points are %v, want %s", points, want)
+	}
+}
+
+func TestSobolFillsEveryInterval(t *testing.T) {
+	// with the origin the first 1024 points have one in each 1/1024th of
+	// every dimension
+	const n = 1024
+	points, err := Sobol(n-1, MaxSobolDims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for j := 0; j < MaxSobolDims; j++ {
+		seen := map[int]bool{0: true}
+		for _, point := range points {
+			seen[int(point[j]*n)] = true
+		}
+		if len(seen) != n {
+			t.Errorf("dimension %d has points in %d of %d intervals", j, len(seen), n)
+		}
+	}
+
+	if _, err := Sobol(8, MaxSobolDims+1); err == nil {
+		t.Errorf("%d dimensions were accepted", MaxSobolDims+1)
+	}
+}
@@ -0,0 +1,188 @@
+package batch
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// Sweep is a grid of parameter values read from a sweep spec file, e.g.,
+//
+//	mode: cartesian          # or zip
+//	steps: 100
+//	parameters:
+//	  life.x_size: [16, 32, 64]
+//	  random_seed: {from: 1, to: 5, step: 1}
+//
+// A parameter is a list of values, a range with an inclusive end (step
+// defaults to 1) or a single value.  The cartesian mode runs every
+// combination of the values; zip runs the first values together, then the
+// second and so on, and needs lists of the same length.
+type Sweep struct {
+	Mode       string
+	Steps      int64
+	Parallel   int
+	OutputDir  string
+	Parameters map[string][]any
+}
+
+// ReadSweep reads a sweep spec in any format viper supports.
+func ReadSweep(filename string) (*Sweep, error) {
+	v := viper.New()
+	v.SetConfigFile(filename)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("unable to read sweep spec %s: %w", filename, err)
+	}
+	sweep := &Sweep{
+		Mode:       v.GetString("mode"),
+		Steps:      v.GetInt64("steps"),
+		Parallel:   v.GetInt("parallel"),
+		OutputDir:  v.GetString("output_dir"),
+		Parameters: map[string][]any{},
+	}
+	if sweep.Mode == "" {
+		sweep.Mode = "cartesian"
+	}
+	if sweep.Mode != "cartesian" && sweep.Mode != "zip" {
+		return nil, fmt.Errorf("%s: unknown mode '%s', expecting cartesian or zip", filename, sweep.Mode)
+	}
+	// parameters are read from the raw map since their keys contain dots
+	parameters, err := cast.ToStringMapE(v.Get("parameters"))
+	if err != nil || len(parameters) == 0 {
+		return nil, fmt.Errorf("%s: no parameters to sweep", filename)
+	}
+	for key, spec := range flatten("", parameters) {
+		values, err := parameterValues(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: parameter %s: %w", filename, key, err)
+		}
+		sweep.Parameters[key] = values
+	}
+	return sweep, nil
+}
+
+// flatten turns nested parameter maps such as {life: {x_size: [...]}} into
+// dotted keys, stopping at lists and ranges
+func flatten(prefix string, parameters map[string]any) map[string]any {
+	flat := map[string]any{}
+	for key, value := range parameters {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, err := cast.ToStringMapE(value); err == nil && !isRange(nested) {
+			for k, v := range flatten(key, nested) {
+				flat[k] = v
+			}
+			continue
+		}
+		flat[key] = value
+	}
+	return flat
+}
+
+func isRange(spec map[string]any) bool {
+	_, from := spec["from"]
+	_, to := spec["to"]
+	return from && to
+}
+
+// parameterValues expands a list, range or single value
+func parameterValues(spec any) ([]any, error) {
+	if rangeSpec, err := cast.ToStringMapE(spec); err == nil {
+		return rangeValues(rangeSpec)
+	}
+	if values, err := cast.ToSliceE(spec); err == nil {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("empty list of values")
+		}
+		return values, nil
+	}
+	return []any{spec}, nil
+}
+
+func rangeValues(spec map[string]any) ([]any, error) {
+	from, err := cast.ToFloat64E(spec["from"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid range start: %w", err)
+	}
+	to, err := cast.ToFloat64E(spec["to"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid range end: %w", err)
+	}
+	step := 1.0
+	if value, ok := spec["step"]; ok {
+		step, err = cast.ToFloat64E(value)
+		if err != nil || step <= 0 {
+			return nil, fmt.Errorf("range step must be a positive number")
+		}
+	}
+	integral := from == math.Trunc(from) && step == math.Trunc(step)
+	var values []any
+	// count the points rather than accumulate to avoid floating point drift
+	n := int(math.Floor((to-from)/step+1e-9)) + 1
+	for i := 0; i < n; i++ {
+		value := from + float64(i)*step
+		if integral {
+			values = append(values, int64(value))
+		} else {
+			values = append(values, value)
+		}
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("range from %v to %v is empty", from, to)
+	}
+	return values, nil
+}
+
+// Runs expands the sweep into its runs, ordered with the last parameter
+// (by name) varying fastest.
+func (s *Sweep) Runs() ([]Run, error) {
+	keys := make([]string, 0, len(s.Parameters))
+	for key := range s.Parameters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var points []map[string]any
+	switch s.Mode {
+	case "zip":
+		n := len(s.Parameters[keys[0]])
+		for _, key := range keys {
+			if len(s.Parameters[key]) != n {
+				return nil, fmt.Errorf("zip sweep needs the same number of values for every parameter, %s has %d not %d", key, len(s.Parameters[key]), n)
+			}
+		}
+		for i := 0; i < n; i++ {
+			point := map[string]any{}
+			for _, key := range keys {
+				point[key] = s.Parameters[key][i]
+			}
+			points = append(points, point)
+		}
+	default:
+		points = []map[string]any{{}}
+		for _, key := range keys {
+			var next []map[string]any
+			for _, point := range points {
+				for _, value := range s.Parameters[key] {
+					extended := map[string]any{key: value}
+					for k, v := range point {
+						extended[k] = v
+					}
+					next = append(next, extended)
+				}
+			}
+			points = next
+		}
+	}
+
+	runs := make([]Run, len(points))
+	width := len(fmt.Sprint(len(points)))
+	for i, point := range points {
+		runs[i] = Run{ID: fmt.Sprintf("run_%0*d", width, i), Params: point}
+	}
+	return runs, nil
+}
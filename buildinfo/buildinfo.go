@@ -0,0 +1,45 @@
+// Package buildinfo identifies the build of the engine: its version and the
+// Go toolchain and VCS state it was built from.
+package buildinfo
+
+import (
+	"runtime"
+	"runtime/debug"
+)
+
+// Version is the version of the engine.  Release builds set it with
+//
+//	go build -ldflags "-X github.com/dacb/goabe/buildinfo.Version=1.2.3"
+var Version = "0.1.0-dev"
+
+// Info describes a build of the engine.
+type Info struct {
+	Version      string `json:"version"`
+	GoVersion    string `json:"go_version"`
+	Module       string `json:"module,omitempty"`
+	Revision     string `json:"vcs_revision,omitempty"`
+	RevisionTime string `json:"vcs_time,omitempty"`
+	Modified     bool   `json:"vcs_modified"`
+}
+
+// Read returns the build of the running binary.  The VCS values are only
+// known when it was built with go build from a checkout.
+func Read() Info {
+	info := Info{Version: Version, GoVersion: runtime.Version()}
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	info.Module = build.Main.Path
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			info.Revision = setting.Value
+		case "vcs.time":
+			info.RevisionTime = setting.Value
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+	return info
+}
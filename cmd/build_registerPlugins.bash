@@ -11,7 +11,7 @@ do
 	file=$dir/$dir.go
 	if [ -r $file ]
 	then
-		awk -v RS='^$' 'END{exit !(index($0,"Init") && index($0,"PreRun") && index($0,"PostRun") && index($0,"Name") && index($0,"Version") && index($0,"Description") && index($0,"Register"))}' $file
+		awk -v RS='^$' 'END{exit !(index($0,"Init") && index($0,"PreRun") && index($0,"PostRun") && index($0,"Name") && index($0,"Version") && index($0,"Description") && index($0,"func Register()"))}' $file
 		if [ $? -eq 0 ]
 		then
 			plugins="$plugins `basename $dir`"
@@ -0,0 +1,182 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/dacb/goabe/batch"
+	"github.com/dacb/goabe/logger"
+	"github.com/dacb/goabe/metrics"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// the calibration settings that override the spec file (from cobra)
+var calibrateSteps int64
+var calibrateParallel int
+var calibrateOutput string
+
+// calibrateCmd represents the calibrate command
+var calibrateCmd = &cobra.Command{
+	Use:   "calibrate <spec file>",
+	Short: "Fit parameters to observations with approximate Bayesian computation",
+	Long: `Fits the priors in the spec to an observed series with ABC rejection or
+ABC-SMC, e.g.,
+
+  method: smc              # or rejection
+  steps: 100
+  seed: 1                  # seed of the sampler
+  output: life.alive_cells # the metric compared to the observations
+  observed: observed.csv   # step and value (or the metric's name) columns
+  distance: rmse           # euclidean, rmse, mae or max
+  particles: 100           # posterior samples
+  generations: 5           # smc: generations after the draws from the priors
+  quantile: 0.5            # smc: next tolerance, a quantile of the distances
+  max_runs: 10000          # smc: runs allowed for a generation
+  samples: 1000            # rejection: draws from the priors
+  tolerance: 5             # rejection: accept within, default is the closest
+  priors:
+    life.x_size: {min: 8, max: 64, type: int}
+
+The priors are uniform over their ranges.  Each candidate is a separate
+goabe process with a seed derived from the base seed, in gen_N/run_M under
+the output directory, and --parallel of them run at once.  The accepted
+particles of each generation are written to gen_N/posterior.csv and the
+final posterior sample to posterior.csv.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		log := logger.Log.With(
+			slog.Group("cmd",
+				slog.String("cmd", "calibrate"),
+				slog.String("spec", args[0]),
+			),
+		)
+		c, err := batch.ReadCalibration(args[0])
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("steps") || c.Steps == 0 {
+			c.Steps = calibrateSteps
+		}
+		if cmd.Flags().Changed("parallel") || c.Parallel == 0 {
+			c.Parallel = calibrateParallel
+		}
+		if cmd.Flags().Changed("output") || c.OutputDir == "" {
+			c.OutputDir = calibrateOutput
+		}
+		if viper.GetString("metrics_file") == "" {
+			return fmt.Errorf("metrics_file must be set to collect the model outputs")
+		}
+		distance, err := metrics.GetDistance(c.Distance)
+		if err != nil {
+			return err
+		}
+		observed, err := metrics.ReadObserved(c.Observed, c.Output)
+		if err != nil {
+			return err
+		}
+		baseSeed := cmd.Context().Value("random_seed").(int64)
+		log.Info(fmt.Sprintf("calibrating %d priors to %d observations of %s with ABC %s",
+			len(c.Priors), len(observed), c.Output, c.Method))
+
+		// every candidate gets its own model seed
+		candidates := 0
+		width := len(fmt.Sprint(c.MaxRuns))
+		if c.Samples > c.MaxRuns {
+			width = len(fmt.Sprint(c.Samples))
+		}
+		simulate := func(generation, first int, values [][]float64) ([]float64, error) {
+			runner, err := newBatchRunner(cmd, filepath.Join(c.OutputDir, fmt.Sprintf("gen_%d", generation)), c.Steps, c.Parallel, log)
+			if err != nil {
+				return nil, err
+			}
+			runs := make([]batch.Run, len(values))
+			for i := range values {
+				params := c.Params(values[i])
+				params["random_seed"] = deriveSeed(baseSeed, candidates)
+				candidates += 1
+				runs[i] = batch.Run{ID: fmt.Sprintf("run_%0*d", width, first+i), Params: params}
+			}
+			results := runner.Execute(cmd.Context(), runs)
+			if err := batchFailures(results); err != nil {
+				return nil, err
+			}
+			distances := make([]float64, len(results))
+			for i, result := range results {
+				series, err := metrics.ReadFile(filepath.Join(result.Dir, viper.GetString("metrics_file")))
+				if err != nil {
+					return nil, err
+				}
+				distances[i] = distance(series[c.Output], observed)
+			}
+			return distances, nil
+		}
+		done := func(generation int, tolerance float64, particles []batch.Particle, runs int) {
+			log.With("generation", generation).With("tolerance", tolerance).With("runs", runs).
+				Info(fmt.Sprintf("accepted %d particles", len(particles)))
+			filename := filepath.Join(c.OutputDir, fmt.Sprintf("gen_%d", generation), "posterior.csv")
+			if err := writePosterior(filename, c, particles); err != nil {
+				log.Error(fmt.Sprintf("unable to write %s: %v", filename, err))
+			}
+		}
+
+		particles, err := c.Run(simulate, done)
+		if err != nil {
+			return err
+		}
+		filename := filepath.Join(c.OutputDir, "posterior.csv")
+		if err := writePosterior(filename, c, particles); err != nil {
+			return err
+		}
+		log.With("posterior", filename).Info("calibration finished")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(calibrateCmd)
+
+	calibrateCmd.Flags().Int64Var(&calibrateSteps, "steps", 0, "steps for each run (default is steps in the spec)")
+	calibrateCmd.Flags().IntVar(&calibrateParallel, "parallel", 1, "number of runs at once (default is parallel in the spec, or 1)")
+	calibrateCmd.Flags().StringVar(&calibrateOutput, "output", "calibrate", "output directory (default is output_dir in the spec, or calibrate)")
+}
+
+// writePosterior writes a CSV with the parameters, weight and distance of
+// each particle.
+func writePosterior(filename string, c *batch.Calibration, particles []batch.Particle) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	header := []string{"particle"}
+	for _, prior := range c.Priors {
+		header = append(header, prior.Key)
+	}
+	writer.Write(append(header, "weight", "distance"))
+	for i, particle := range particles {
+		line := []string{strconv.Itoa(i)}
+		params := c.Params(particle.Values)
+		for _, prior := range c.Priors {
+			line = append(line, cast.ToString(params[prior.Key]))
+		}
+		line = append(line,
+			strconv.FormatFloat(particle.Weight, 'g', -1, 64),
+			strconv.FormatFloat(particle.Distance, 'g', -1, 64),
+		)
+		writer.Write(line)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
@@ -4,16 +4,35 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/dacb/goabe/plugins"
+
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
 )
 
+// Config is the engine's own (top level) configuration.  The values set
+// in init are the defaults.
 type Config struct {
-	LogLevel   string `mapstructure:"log_level"`
-	LogFile    string `mapstructure:"log_file"`
-	Substeps   int    `mapstructure:"substeps"`
-	RandomSeed int64  `mapstructure:"random_seed"`
-	PluginDir  string `mapstructure:"plugin_dir"`
+	LogLevel          string            `mapstructure:"log_level" desc:"threshold for all log streams"`
+	LogFileLevel      string            `mapstructure:"log_file_level" desc:"threshold for the log file, log_level if empty"`
+	LogConsoleLevel   string            `mapstructure:"log_console_level" desc:"threshold for the console log, log_level if empty"`
+	LogLevels         map[string]string `mapstructure:"log_levels" desc:"thresholds by plugin or actor, e.g. {\"life\": \"DEBUG\"}"`
+	LogFile           string            `mapstructure:"log_file" desc:"JSON log file, empty for none"`
+	LogFileAppend     bool              `mapstructure:"log_file_append" desc:"append to the log file instead of truncating it"`
+	LogFileMaxSize    int64             `mapstructure:"log_file_max_size" min:"0" desc:"rotate the log file at this many MB, 0 for never"`
+	LogFileMaxAge     time.Duration     `mapstructure:"log_file_max_age" desc:"rotate the log file after this duration, e.g. 24h"`
+	LogFileMaxBackups int               `mapstructure:"log_file_max_backups" min:"0" desc:"number of rotated log files to keep, 0 for all"`
+	LogFileCompress   bool              `mapstructure:"log_file_compress" desc:"gzip rotated log files"`
+	LogConsole        string            `mapstructure:"log_console" oneof:"stdout,stderr" desc:"stream for the console log"`
+	LogConsoleFmt     string            `mapstructure:"log_console_format" oneof:"text,json,pretty,none" desc:"format of the console log"`
+	LogRunDir         bool              `mapstructure:"log_run_dir" desc:"put the log file in a subdirectory named by the run id"`
+	RunID             string            `mapstructure:"run_id" desc:"identifier of the run, generated if empty"`
+	Substeps          int               `mapstructure:"substeps" min:"1" desc:"number of substeps in each step"`
+	StepDuration      float64           `mapstructure:"step_duration" above:"0" desc:"simulation time that passes in each step, for scheduled events"`
+	RandomSeed        string            `mapstructure:"random_seed" match:"^(random|-?[0-9]+)$" desc:"seed for the random number generators, or random for a new seed each run"`
+	ManifestFile      string            `mapstructure:"manifest_file" desc:"file to write the run manifest to, empty for none"`
+	MetricsFile       string            `mapstructure:"metrics_file" desc:"CSV file for the per step metrics published by plugins, empty for none"`
+	PluginDir         string            `mapstructure:"plugin_dir" file:"input" desc:"directory of plugins"`
+	Plugins           []string          `mapstructure:"plugins" desc:"plugin instances to run, e.g. life#a and life#b, or all plugins once if empty"`
 }
 
 // configCmd represents the config command
@@ -21,7 +40,7 @@ var configCmd = &cobra.Command{
 	Use:   "config",
 	Short: "Configuration management tools",
 	Long: `These tools include creating a default configuration file, validating
-a configuration file, and other aspects of working with configuraiton data.`,
+a configuration file, and other aspects of working with configuration data.`,
 	//Run: func(cmd *cobra.Command, args []string) {
 	//	fmt.Println("config called")
 	//},
@@ -44,8 +63,17 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
-	viper.SetDefault("log_level", string(log_level_text))
-	viper.SetDefault("log_file", "goabe.log.json")
-	viper.SetDefault("substeps", 10)
-	viper.SetDefault("random_seed", time.Now().UnixNano())
+	plugins.RegisterConfig("", Config{
+		LogLevel:      string(log_level_text),
+		LogLevels:     map[string]string{},
+		Plugins:       []string{},
+		LogFile:       "goabe.log.json",
+		LogConsole:    "stdout",
+		LogConsoleFmt: "text",
+		Substeps:      10,
+		StepDuration:  1,
+		RandomSeed:    randomSeed,
+		ManifestFile:  "goabe.manifest.json",
+		MetricsFile:   "goabe.metrics.csv",
+	})
 }
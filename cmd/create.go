@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 
 	"github.com/dacb/goabe/logger"
@@ -11,30 +12,41 @@ import (
 	"github.com/spf13/viper"
 )
 
+// the format of the file to create when no file name is given (from cobra)
+var createFormat string
+
 // createCmd represents the create command
 var createCmd = &cobra.Command{
-	Use:   "create",
+	Use:   "create [file]",
 	Short: "Create a configuration file with default options.",
-	Long:  `This saves a default configuration to the file for future modificaiton and usage`,
+	Long: `This saves a default configuration to the file for future modification and usage.
+The format (json, yaml or toml) is taken from the extension of the file, which
+defaults to goabe.json or goabe.<format> with --format.`,
+	Args: cobra.MaximumNArgs(1),
 	Run: func(cmd *cobra.Command, args []string) {
+		filename := "goabe." + createFormat
+		if len(args) > 0 {
+			filename = args[0]
+		}
 		log := logger.Log.With(
 			slog.Group("cmd",
 				slog.String("cmd", "config"),
 				slog.String("sub", "create"),
-				slog.String("config_file", viper.ConfigFileUsed()),
+				slog.String("config_file", filename),
 			),
 		)
 		log.Info("create called to save out the configuration; will not overwrite an existing file")
 		ctx := context.WithValue(cmd.Context(), "log", log)
 		// load the plugins to get their default configs, if any
-		err := plugins.LoadPlugins(ctx)
+		engine := plugins.NewEngine()
+		err := plugins.LoadPlugins(ctx, engine)
 		if err != nil {
 			log.Error("an error occurred loading the plugins")
 			panic(err)
 		}
-		err = viper.SafeWriteConfig()
+		err = viper.SafeWriteConfigAs(filename)
 		if err != nil {
-			log.Error("unable to safe write configuration file, does it already exist?")
+			log.Error(fmt.Sprintf("unable to safe write configuration file, does it already exist? %v", err))
 		}
 	},
 }
@@ -51,4 +63,5 @@ func init() {
 	// Cobra supports local flags which will only run when this command
 	// is called directly, e.g.:
 	// createCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+	createCmd.Flags().StringVar(&createFormat, "format", "json", "format of the default file: json, yaml or toml")
 }
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dacb/goabe/logger"
+
+	"github.com/spf13/cobra"
+)
+
+// filterCmd represents the log filter command
+var filterCmd = &cobra.Command{
+	Use:   "filter [log file]",
+	Short: "Print the log records that match the filters",
+	Long: `Prints the JSON lines from the log that match all of the given filters,
+e.g., to see the warnings and errors from the life plugin in steps 10 to 20:
+
+  goabe log filter --plugin life --level WARN --from-step 10 --to-step 20`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{outputAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		return readLog(args, func(r *logger.Record) error {
+			_, err := fmt.Fprintln(out, r.Line)
+			return err
+		})
+	},
+}
+
+func init() {
+	logCmd.AddCommand(filterCmd)
+}
@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// readConfigFile reads a configuration file in any format viper supports
+// (json, yaml, toml, ...) along with the files it extends.  The extends key
+// names one file or a list of files, relative to the file naming them, whose
+// settings are merged in order and then overridden by the file's own.
+func readConfigFile(filename string, seen []string) (map[string]any, error) {
+	filename, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, err
+	}
+	for _, previous := range seen {
+		if previous == filename {
+			return nil, fmt.Errorf("configuration file '%s' extends itself", filename)
+		}
+	}
+	seen = append(seen, filename)
+
+	v := viper.New()
+	v.SetConfigFile(filename)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("unable to read configuration from %s: %w", filename, err)
+	}
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		// viper reads JSON numbers as float64, which rounds integers
+		// past 2^53 such as seeds, so they are read again exactly
+		exact, err := readJSONFile(filename)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read configuration from %s: %w", filename, err)
+		}
+		if err := v.MergeConfigMap(exact); err != nil {
+			return nil, err
+		}
+	}
+	settings := v.AllSettings()
+
+	merged := map[string]any{}
+	var bases []string
+	if extends, ok := settings["extends"]; ok {
+		bases, err = cast.ToStringSliceE(extends)
+		if err != nil {
+			return nil, fmt.Errorf("%s: extends must be a file name or a list of them", filename)
+		}
+	}
+	for _, base := range bases {
+		if !filepath.IsAbs(base) {
+			base = filepath.Join(filepath.Dir(filename), base)
+		}
+		baseSettings, err := readConfigFile(base, seen)
+		if err != nil {
+			return nil, err
+		}
+		mergeSettings(merged, baseSettings)
+	}
+	delete(settings, "extends")
+	mergeSettings(merged, settings)
+	return merged, nil
+}
+
+// readJSONFile decodes a JSON object with its integers as int64
+func readJSONFile(filename string) (map[string]any, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	decoder := json.NewDecoder(file)
+	decoder.UseNumber()
+	var settings map[string]any
+	if err := decoder.Decode(&settings); err != nil {
+		return nil, err
+	}
+	return exactNumbers(settings).(map[string]any), nil
+}
+
+// findConfigFile returns the file named name with any of the extensions
+// viper supports in dir, e.g., goabe.json or goabe.yaml, or "" if there is
+// none
+func findConfigFile(dir, name string) string {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	for _, ext := range viper.SupportedExts {
+		filename := filepath.Join(dir, name+"."+ext)
+		if info, err := os.Stat(filename); err == nil && !info.IsDir() {
+			return filename
+		}
+	}
+	return ""
+}
+
+// mergeSettings deeply merges src into dst, with src taking precedence
+func mergeSettings(dst, src map[string]any) {
+	for key, value := range src {
+		if srcMap, ok := value.(map[string]any); ok {
+			if dstMap, ok := dst[key].(map[string]any); ok {
+				mergeSettings(dstMap, srcMap)
+				continue
+			}
+			copied := map[string]any{}
+			mergeSettings(copied, srcMap)
+			dst[key] = copied
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+// applyProfile overlays the named profile from the profiles section of the
+// settings onto the rest of them.
+func applyProfile(settings map[string]any, profile string) error {
+	profiles, _ := settings["profiles"].(map[string]any)
+	overlay, ok := profiles[profile].(map[string]any)
+	if !ok {
+		return fmt.Errorf("profile '%s' is not defined in the profiles section of the configuration", profile)
+	}
+	mergeSettings(settings, overlay)
+	return nil
+}
@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+// the format to show the plugin in (from cobra)
+var infoOutput string
+
+// infoCmd represents the plugin info command
+var infoCmd = &cobra.Command{
+	Use:   "info <name>",
+	Short: "Show the details of a plugin",
+	Long: `Prints a plugin's description and version, its hooks by substep, the
+configuration keys it declares with their defaults and the plugins it
+depends on.  The name is not case sensitive.`,
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{outputAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		infos, err := loadPluginInfo(cmd)
+		if err != nil {
+			return err
+		}
+		var info *pluginInfo
+		var names []string
+		for i := range infos {
+			names = append(names, infos[i].Name)
+			if strings.EqualFold(infos[i].Name, args[0]) {
+				info = &infos[i]
+			}
+		}
+		if info == nil {
+			return fmt.Errorf("no plugin named '%s', the plugins are %s", args[0], strings.Join(names, ", "))
+		}
+
+		out := cmd.OutOrStdout()
+		switch infoOutput {
+		case "text":
+			fmt.Fprintf(out, "%s %s\n%s\n", info.Name, info.Version, info.Description)
+			writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			if info.Error != "" {
+				fmt.Fprintf(writer, "\nunable to initialize, the hooks are not known: %s\n", info.Error)
+			}
+			fmt.Fprintln(writer, "\nhooks:")
+			for _, hook := range info.Hooks {
+				var actors []string
+				if hook.Core {
+					actors = append(actors, "core")
+				}
+				if hook.Thread {
+					actors = append(actors, "thread")
+				}
+				fmt.Fprintf(writer, "  substep %d\t%s\t%s\n", hook.SubStep, strings.Join(actors, ","), hook.Description)
+			}
+			fmt.Fprintln(writer, "\nconfig:")
+			for _, key := range info.Config {
+				value, err := json.Marshal(key.Default)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(writer, "  %s\t%s\t%s\t%s\n", key.Key, key.Type, value, key.Description)
+			}
+			dependencies := "none"
+			if len(info.Dependencies) > 0 {
+				dependencies = strings.Join(info.Dependencies, ", ")
+			}
+			fmt.Fprintf(writer, "\ndependencies: %s\n", dependencies)
+			return writer.Flush()
+		case "json":
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(info)
+		default:
+			return fmt.Errorf("unknown output format '%s', expecting text or json", infoOutput)
+		}
+	},
+}
+
+func init() {
+	pluginCmd.AddCommand(infoCmd)
+
+	infoCmd.Flags().StringVarP(&infoOutput, "output", "o", "text", "output format: text or json")
+}
@@ -2,45 +2,53 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
+	"text/tabwriter"
 
 	"github.com/dacb/goabe/logger"
 	"github.com/dacb/goabe/plugins"
 	"github.com/spf13/cobra"
 )
 
+// the format to list the plugins in (from cobra)
+var listOutput string
+
 // listCmd represents the list command
 var listCmd = &cobra.Command{
 	Use:   "list",
-	Short: "A brief description of your command",
-	Long: `A longer description that spans multiple lines and likely contains examples
-and usage of using your command. For example:
-
-Cobra is a CLI library for Go that empowers applications.
-This application is a tool to generate the needed files
-to quickly create a Cobra application.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		log := logger.Log.With("cmd", "plugin list")
-		log.Info("plugin list called")
-		ctx := context.WithValue(cmd.Context(), "log", log)
-
-		err := plugins.LoadPlugins(ctx)
+	Short: "List the compiled-in plugins",
+	Long: `Prints the name, version, number of hooks and description of every plugin
+compiled into this binary, as a table or, with --output json, with the
+details that 'plugin info' shows for each.`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{outputAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		infos, err := loadPluginInfo(cmd)
 		if err != nil {
-			log.Error("an error occurred loading the plugins")
-			panic(err)
+			return err
 		}
-
-		for _, plugin := range plugins.LoadedPlugins {
-			name := plugin.Name()
-			description := plugin.Description()
-			hooks := plugin.GetHooks()
-			major, minor, patch := plugin.Version()
-			log.Info(fmt.Sprintf("plugin: %s v%d.%d.%d - %s - %d hooks",
-				name, major, minor, patch, description, len(hooks),
-			))
-			for _, hook := range hooks {
-				log.Info(fmt.Sprintf("plugin: %s - hook '%s' at substep %d (%0x, %0x)", name, hook.Description, hook.SubStep, hook.Core, hook.Thread))
+		out := cmd.OutOrStdout()
+		switch listOutput {
+		case "table":
+			writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			fmt.Fprintln(writer, "NAME\tVERSION\tHOOKS\tDESCRIPTION")
+			for _, info := range infos {
+				hooks := fmt.Sprint(len(info.Hooks))
+				if info.Error != "" {
+					hooks = "?"
+				}
+				fmt.Fprintf(writer, "%s\t%s\t%s\t%s\n", info.Name, info.Version, hooks, info.Description)
 			}
+			return writer.Flush()
+		case "json":
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(infos)
+		default:
+			return fmt.Errorf("unknown output format '%s', expecting table or json", listOutput)
 		}
 	},
 }
@@ -48,13 +56,102 @@ to quickly create a Cobra application.`,
 func init() {
 	pluginCmd.AddCommand(listCmd)
 
-	// Here you will define your flags and configuration settings.
+	listCmd.Flags().StringVarP(&listOutput, "output", "o", "table", "output format: table or json")
+}
+
+// pluginInfo describes a plugin for the list and info commands.
+type pluginInfo struct {
+	Name         string       `json:"name"`
+	Version      string       `json:"version"`
+	Description  string       `json:"description"`
+	Hooks        []hookInfo   `json:"hooks"`
+	Config       []configInfo `json:"config"`
+	Dependencies []string     `json:"dependencies"`
+	// Error is why the plugin could not be initialized, in which case its
+	// hooks are not known
+	Error string `json:"error,omitempty"`
+}
+
+// hookInfo is a hook of a plugin; Core and Thread report which of its
+// functions are set.
+type hookInfo struct {
+	SubStep     int    `json:"substep"`
+	Description string `json:"description"`
+	Core        bool   `json:"core"`
+	Thread      bool   `json:"thread"`
+}
+
+// configInfo is a configuration key declared by a plugin.
+type configInfo struct {
+	Key         string `json:"key"`
+	Type        string `json:"type"`
+	Default     any    `json:"default"`
+	Description string `json:"description"`
+}
+
+// discardDisplay is given to the plugins when describing them so that
+// those that draw their state, e.g., life in Init, do not write to stdout.
+type discardDisplay struct{}
 
-	// Cobra supports Persistent Flags which will work for this command
-	// and all subcommands, e.g.:
-	// listCmd.PersistentFlags().String("foo", "", "A help for foo")
+func (discardDisplay) Draw(panel string, lines []string) {}
+
+// loadPluginInfo initializes the plugins, since their hooks may depend on
+// it, and describes each of them.  A plugin that cannot be initialized,
+// e.g., because its input file is missing, is described with the error
+// rather than stopping the others from being described.
+func loadPluginInfo(cmd *cobra.Command) ([]pluginInfo, error) {
+	log := logger.Log.With("cmd", cmd.CommandPath())
+	ctx := context.WithValue(cmd.Context(), "log", log)
+	ctx = context.WithValue(ctx, "display", plugins.Display(discardDisplay{}))
+	var infos []pluginInfo
+	engine := plugins.NewEngine()
+	for _, plugin := range engine.Plugins {
+		err := plugins.LoadPlugin(ctx, engine, plugin)
+		if err != nil {
+			log.With("plugin", plugin.Name()).Error(fmt.Sprintf("unable to initialize the plugin: %v", err))
+		}
+		infos = append(infos, describePlugin(plugin, err))
+	}
+	return infos, plugins.ShutdownPlugins(ctx, engine)
+}
 
-	// Cobra supports local flags which will only run when this command
-	// is called directly, e.g.:
-	// listCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
+// describePlugin describes a plugin, without its hooks if initErr is the
+// error initializing it
+func describePlugin(plugin plugins.Plugin, initErr error) pluginInfo {
+	major, minor, patch := plugin.Version()
+	info := pluginInfo{
+		Name:         plugin.Name(),
+		Version:      fmt.Sprintf("%d.%d.%d", major, minor, patch),
+		Description:  plugin.Description(),
+		Hooks:        []hookInfo{},
+		Config:       []configInfo{},
+		Dependencies: plugins.GetDependencies(plugin.Name()),
+	}
+	if info.Dependencies == nil {
+		info.Dependencies = []string{}
+	}
+	if initErr != nil {
+		info.Error = initErr.Error()
+	} else {
+		for _, hook := range plugin.GetHooks() {
+			info.Hooks = append(info.Hooks, hookInfo{
+				SubStep:     hook.SubStep,
+				Description: hook.Description,
+				Core:        hook.Core != nil,
+				Thread:      hook.Thread != nil,
+			})
+		}
+	}
+	sort.SliceStable(info.Hooks, func(i, j int) bool { return info.Hooks[i].SubStep < info.Hooks[j].SubStep })
+	if schema := plugins.GetConfigSchema(strings.ToLower(plugin.Name())); schema != nil {
+		for _, key := range schema.Keys {
+			info.Config = append(info.Config, configInfo{
+				Key:         key.Key,
+				Type:        key.Type,
+				Default:     key.Default,
+				Description: key.Description,
+			})
+		}
+	}
+	return info
 }
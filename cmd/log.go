@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dacb/goabe/logger"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// logCmd represents the log command
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "Tools for querying and summarizing JSON run logs",
+	Long: `These tools read the JSON log written by a run (log_file in the
+configuration, or the file given as the argument) to filter its records,
+extract the step timing series, and summarize the run.`,
+}
+
+// the filters shared by the log subcommands (from cobra)
+var logFilter struct {
+	level    string
+	plugin   string
+	actor    string
+	fromStep int64
+	toStep   int64
+}
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+
+	logCmd.PersistentFlags().StringVar(&logFilter.level, "level", "", "only records at or above this level")
+	logCmd.PersistentFlags().StringVar(&logFilter.plugin, "plugin", "", "only records from this plugin")
+	logCmd.PersistentFlags().StringVar(&logFilter.actor, "actor", "", "only records from this actor, e.g. core, thread or thread_3")
+	logCmd.PersistentFlags().Int64Var(&logFilter.fromStep, "from-step", -1, "only records at or after this step")
+	logCmd.PersistentFlags().Int64Var(&logFilter.toStep, "to-step", -1, "only records at or before this step")
+}
+
+// readLog calls fn for each record in the log file named by args, or the
+// configured log_file, that passes the filters given on the command line.
+func readLog(args []string, fn func(*logger.Record) error) error {
+	filename := viper.GetString("log_file")
+	if len(args) > 0 {
+		filename = args[0]
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	match, err := logMatcher()
+	if err != nil {
+		return err
+	}
+	err = logger.ReadRecords(file, func(r *logger.Record) error {
+		if !match(r) {
+			return nil
+		}
+		return fn(r)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+	return nil
+}
+
+func logMatcher() (func(*logger.Record) bool, error) {
+	filter := logFilter
+	var minLevel *int
+	if filter.level != "" {
+		level, err := logger.ParseLevel(filter.level)
+		if err != nil {
+			return nil, err
+		}
+		l := int(level)
+		minLevel = &l
+	}
+	filter.plugin = strings.ToLower(filter.plugin)
+	stepped := filter.fromStep >= 0 || filter.toStep >= 0
+
+	return func(r *logger.Record) bool {
+		if minLevel != nil && int(r.Level) < *minLevel {
+			return false
+		}
+		if filter.plugin != "" && r.Plugin != filter.plugin {
+			return false
+		}
+		// thread matches all of thread_0, thread_1, ...
+		if filter.actor != "" && r.Actor != filter.actor && !strings.HasPrefix(r.Actor, filter.actor+"_") {
+			return false
+		}
+		if stepped {
+			if !r.HasStep {
+				return false
+			}
+			if filter.fromStep >= 0 && r.Step < filter.fromStep {
+				return false
+			}
+			if filter.toStep >= 0 && r.Step > filter.toStep {
+				return false
+			}
+		}
+		return true
+	}, nil
+}
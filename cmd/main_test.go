@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestMain lets the tests run the test binary as goabe, e.g., for the runs
+// of a batch, which are started with os.Executable
+func TestMain(m *testing.M) {
+	if os.Getenv("GOABE_TEST_MAIN") != "" {
+		Execute()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// goabe runs the test binary as goabe in dir and returns its output
+func goabe(t *testing.T, dir string, args ...string) string {
+	output, err := runGoabe(t, dir, args...)
+	if err != nil {
+		t.Fatalf("goabe %s: %v\n%s", strings.Join(args, " "), err, output)
+	}
+	return output
+}
+
+// goabeFails runs the test binary as goabe in dir, expecting it to fail,
+// and returns its output
+func goabeFails(t *testing.T, dir string, args ...string) string {
+	output, err := runGoabe(t, dir, args...)
+	if err == nil {
+		t.Fatalf("goabe %s succeeded\n%s", strings.Join(args, " "), output)
+	}
+	return output
+}
+
+func runGoabe(t *testing.T, dir string, args ...string) (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(executable, args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOABE_TEST_MAIN=1")
+	output, err := cmd.CombinedOutput()
+	return string(output), err
+}
+
+// writeFiles writes files, by name relative to dir, creating directories
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, contents := range files {
+		filename := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), 0777); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(contents), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
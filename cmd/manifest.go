@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/dacb/goabe/logger"
+	"github.com/dacb/goabe/manifest"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// manifestCmd represents the manifest command
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Tools for run manifests",
+	Long: `Every run writes a manifest (manifest_file in the configuration) that records
+the build, plugins, effective configuration, seed, command line, host and
+times of the run and the checksums of its inputs and outputs.`,
+}
+
+// manifestVerifyCmd represents the manifest verify command
+var manifestVerifyCmd = &cobra.Command{
+	Use:   "verify [manifest file]",
+	Short: "Check the inputs and outputs of a run against its manifest",
+	Long: `Recomputes the checksums of the input and output files recorded in the
+manifest (manifest_file in the configuration, in the directory of run_id
+with log_run_dir, or the file given as the argument) and reports those that are missing or changed.  Relative paths
+are looked for from the run's working directory, then next to the manifest.`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{outputAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filename := viper.GetString("manifest_file")
+		if len(args) > 0 {
+			filename = args[0]
+		} else if viper.GetBool("log_run_dir") {
+			// the run wrote its manifest in the directory named by its id,
+			// which is only known here if run_id was set
+			id := viper.GetString("run_id")
+			if id == "" {
+				return fmt.Errorf("log_run_dir is set, so give the manifest of the run to verify, e.g., %s", logger.FindRunPath(filename, "<run id>"))
+			}
+			filename = logger.FindRunPath(filename, id)
+		}
+		if filename == "" {
+			return fmt.Errorf("manifest_file is empty, so give the manifest to verify")
+		}
+		record, err := manifest.Read(filename)
+		if err != nil {
+			return err
+		}
+		cmd.SilenceUsage = true
+		out := cmd.OutOrStdout()
+		problems := record.Verify(filepath.Dir(filename))
+		for _, problem := range problems {
+			fmt.Fprintf(out, "error: %s\n", problem)
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("%s: %d of %d files do not match", filename, len(problems), len(record.Inputs)+len(record.Outputs))
+		}
+		fmt.Fprintf(out, "%s: %d inputs and %d outputs match\n", filename, len(record.Inputs), len(record.Outputs))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(manifestCmd)
+	manifestCmd.AddCommand(manifestVerifyCmd)
+}
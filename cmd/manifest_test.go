@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestManifestVerifyFindsTheRunDirectory(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"goabe.json": `{"log_run_dir": true, "run_id": "first", "metrics_file": "", "plugins": ["life"], "life": {"x_size": 4, "y_size": 4}}`,
+	})
+	goabe(t, dir, "run", "--steps", "1")
+	if output := goabe(t, dir, "manifest", "verify"); !strings.Contains(output, "first/goabe.manifest.json: ") {
+		t.Errorf("the manifest in the run's directory was not verified:\n%s", output)
+	}
+
+	// without a run_id the run's directory is not known
+	if output := goabeFails(t, dir, "--set", "run_id=", "manifest", "verify"); !strings.Contains(output, "give the manifest of the run") {
+		t.Errorf("verify without a run id did not ask for the manifest:\n%s", output)
+	}
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"go/format"
+	"go/token"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+//go:embed templates/*.tmpl
+var templates embed.FS
+
+// the root of the goabe source tree to create the plugin in (from cobra)
+var newDir string
+
+// plugin names must be usable as Go package and directory names
+var pluginNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+
+// newCmd represents the plugin new command
+var newCmd = &cobra.Command{
+	Use:   "new <name>",
+	Short: "Create a new plugin from a template",
+	Long: `Creates the package <name> in the goabe source tree with a plugin that has
+all of the required functions, a config struct, a thread and a core hook and
+a test that uses the goabetest harness, then regenerates cmd/registerPlugins.go so the plugin is compiled
+in.  Rebuild goabe with make and the new plugin runs with the others.`,
+	Args:        cobra.ExactArgs(1),
+	Annotations: map[string]string{outputAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		name := args[0]
+		if !pluginNamePattern.MatchString(name) || token.IsKeyword(name) {
+			return fmt.Errorf("'%s' is not a valid plugin name, use lower case letters, digits and _", name)
+		}
+		module, err := os.ReadFile(filepath.Join(newDir, "go.mod"))
+		if err != nil || !strings.Contains(string(module), "module github.com/dacb/goabe\n") {
+			return fmt.Errorf("%s is not the root of the goabe source tree, see --dir", newDir)
+		}
+		dir := filepath.Join(newDir, name)
+		if _, err := os.Stat(dir); err == nil {
+			return fmt.Errorf("%s already exists", dir)
+		}
+		if err := os.Mkdir(dir, 0777); err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		files := map[string]string{
+			"plugin.go.tmpl":      name + ".go",
+			"plugin_test.go.tmpl": name + "_test.go",
+		}
+		for source, target := range files {
+			text, err := renderTemplate(source, map[string]string{"Package": name})
+			if err != nil {
+				return err
+			}
+			filename := filepath.Join(dir, target)
+			if err := os.WriteFile(filename, text, 0666); err != nil {
+				return err
+			}
+			fmt.Fprintf(out, "created %s\n", filename)
+		}
+
+		registration := filepath.Join(newDir, "cmd", "registerPlugins.go")
+		generate := exec.Command("bash", "./cmd/build_registerPlugins.bash")
+		generate.Dir = newDir
+		generate.Stderr = os.Stderr
+		code, err := generate.Output()
+		if err != nil {
+			return fmt.Errorf("unable to regenerate %s: %w", registration, err)
+		}
+		if !bytes.Contains(code, []byte(name+".Register()")) {
+			return fmt.Errorf("cmd/build_registerPlugins.bash did not find the new plugin")
+		}
+		if err := os.WriteFile(registration, code, 0666); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "regenerated %s\n", registration)
+		fmt.Fprintf(out, "build with make, then try ./goabe plugin info %s\n", name)
+		return nil
+	},
+}
+
+func init() {
+	pluginCmd.AddCommand(newCmd)
+
+	newCmd.Flags().StringVar(&newDir, "dir", ".", "root of the goabe source tree")
+}
+
+// renderTemplate fills in one of the plugin templates and formats the code
+func renderTemplate(name string, data any) ([]byte, error) {
+	parsed, err := template.ParseFS(templates, "templates/"+name)
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	if err := parsed.Execute(buf, data); err != nil {
+		return nil, err
+	}
+	return format.Source(buf.Bytes())
+}
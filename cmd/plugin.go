@@ -8,7 +8,8 @@ import (
 var pluginCmd = &cobra.Command{
 	Use:   "plugin",
 	Short: "A set of tools for interacting with goabe plugins",
-	Long:  `Some tools, including 'list' to work with goabe plugins.`,
+	Long: `Tools to work with the plugins compiled into goabe: 'list' lists them and
+'info' shows the hooks, configuration and dependencies of one.`,
 }
 
 func init() {
@@ -0,0 +1,216 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/dacb/goabe/batch"
+	"github.com/dacb/goabe/logger"
+	"github.com/dacb/goabe/metrics"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// the replicate settings (from cobra)
+var replicateN int
+var replicateSteps int64
+var replicateParallel int
+var replicateOutput string
+var replicateQuantiles string
+
+// replicateDone is written in the directory of a replicate once it has
+// finished, so that it is not run again when resuming
+const replicateDone = "done"
+
+// replicatePlan is saved in the output directory so an interrupted set of
+// replicates can be resumed with the same seeds.
+type replicatePlan struct {
+	BaseSeed int64 `json:"base_seed"`
+	N        int   `json:"n"`
+	Steps    int64 `json:"steps"`
+}
+
+// replicateCmd represents the replicate command
+var replicateCmd = &cobra.Command{
+	Use:   "replicate",
+	Short: "Run replicates of the model and aggregate their metrics",
+	Long: `Runs the configured model --n times, each with a seed derived from the
+base seed (--seed or random_seed), in its own directory under the output
+directory.  The per step metrics that plugins publish are then summarized
+across the replicates into summary.csv with the mean, variance, minimum,
+maximum and quantiles of each metric at each step.
+
+Running the command again with the same output directory resumes it: the
+replicates that already finished are not run again.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		log := logger.Log.With(
+			slog.Group("cmd",
+				slog.String("cmd", "replicate"),
+				slog.Int("n", replicateN),
+			),
+		)
+		probabilities, err := parseQuantiles(replicateQuantiles)
+		if err != nil {
+			return err
+		}
+		if viper.GetString("metrics_file") == "" {
+			return fmt.Errorf("metrics_file must be set to collect the replicates' metrics")
+		}
+		runner, err := newBatchRunner(cmd, replicateOutput, replicateSteps, replicateParallel, log)
+		if err != nil {
+			return err
+		}
+		logDone := runner.Done
+		runner.Done = func(result batch.Result) {
+			if result.Err == nil {
+				if err := os.WriteFile(filepath.Join(result.Dir, replicateDone), nil, 0666); err != nil {
+					log.With("run", result.ID).Warn(fmt.Sprintf("unable to mark the replicate as done, it will be run again when resuming: %s", err))
+				}
+			}
+			logDone(result)
+		}
+
+		plan, err := loadReplicatePlan(cmd, log)
+		if err != nil {
+			return err
+		}
+		log.With("base_seed", plan.BaseSeed).Info(fmt.Sprintf("%d replicates of %d steps into %s", plan.N, plan.Steps, replicateOutput))
+
+		// the replicates marked as done finished, the rest are (re)run
+		runs := make([]batch.Run, plan.N)
+		var pending []batch.Run
+		width := len(fmt.Sprint(plan.N))
+		for i := range runs {
+			runs[i] = batch.Run{
+				ID:     fmt.Sprintf("rep_%0*d", width, i),
+				Params: map[string]any{"random_seed": deriveSeed(plan.BaseSeed, i)},
+				Dir:    filepath.Join(replicateOutput, fmt.Sprintf("rep_%0*d", width, i)),
+			}
+			if _, err := os.Stat(filepath.Join(runs[i].Dir, replicateDone)); err != nil {
+				pending = append(pending, runs[i])
+			}
+		}
+		if len(pending) < len(runs) {
+			log.Info(fmt.Sprintf("resuming, %d of %d replicates already finished", len(runs)-len(pending), len(runs)))
+		}
+		results := runner.Execute(cmd.Context(), pending)
+		if err := batchFailures(results); err != nil {
+			return fmt.Errorf("%w, run the command again to resume", err)
+		}
+
+		var series []metrics.Series
+		for _, run := range runs {
+			s, err := metrics.ReadFile(filepath.Join(run.Dir, viper.GetString("metrics_file")))
+			if err != nil {
+				return err
+			}
+			series = append(series, s)
+		}
+		summary := filepath.Join(replicateOutput, "summary.csv")
+		if err := writeSummary(summary, metrics.Summarize(series, probabilities), probabilities); err != nil {
+			return err
+		}
+		log.With("summary", summary).Info("replicates finished")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(replicateCmd)
+
+	replicateCmd.Flags().IntVar(&replicateN, "n", 10, "number of replicates")
+	replicateCmd.Flags().Int64Var(&replicateSteps, "steps", 0, "steps for each replicate")
+	replicateCmd.Flags().IntVar(&replicateParallel, "parallel", 1, "number of replicates run at once")
+	replicateCmd.Flags().StringVar(&replicateOutput, "output", "replicates", "output directory")
+	replicateCmd.Flags().StringVar(&replicateQuantiles, "quantiles", "0.05,0.5,0.95", "comma separated quantiles to report")
+}
+
+// loadReplicatePlan reads the plan of an earlier invocation, if any, so the
+// seeds are the same when resuming, or saves a new one.
+func loadReplicatePlan(cmd *cobra.Command, log *slog.Logger) (*replicatePlan, error) {
+	filename := filepath.Join(replicateOutput, "replicate.json")
+	plan := &replicatePlan{
+		BaseSeed: cmd.Context().Value("random_seed").(int64),
+		N:        replicateN,
+		Steps:    replicateSteps,
+	}
+	data, err := os.ReadFile(filename)
+	if err == nil {
+		previous := &replicatePlan{}
+		if err := json.Unmarshal(data, previous); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		if seedFlag != "" && previous.BaseSeed != plan.BaseSeed {
+			return nil, fmt.Errorf("%s was made with base seed %d, not %d; use a new output directory", filename, previous.BaseSeed, plan.BaseSeed)
+		}
+		if previous.Steps != plan.Steps {
+			return nil, fmt.Errorf("%s was made with %d steps, not %d; use a new output directory", filename, previous.Steps, plan.Steps)
+		}
+		// more replicates can be added to a finished set
+		plan.BaseSeed = previous.BaseSeed
+		if previous.N > plan.N {
+			plan.N = previous.N
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	data, err = json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return plan, os.WriteFile(filename, append(data, '\n'), 0666)
+}
+
+func parseQuantiles(text string) ([]float64, error) {
+	var probabilities []float64
+	for _, field := range strings.Split(text, ",") {
+		if strings.TrimSpace(field) == "" {
+			continue
+		}
+		p, err := strconv.ParseFloat(strings.TrimSpace(field), 64)
+		if err != nil || p < 0 || p > 1 {
+			return nil, fmt.Errorf("invalid quantile '%s', expecting a number from 0 to 1", field)
+		}
+		probabilities = append(probabilities, p)
+	}
+	return probabilities, nil
+}
+
+// writeSummary writes the metric summaries as CSV with one line per metric
+// and step.
+func writeSummary(filename string, summaries []metrics.Summary, probabilities []float64) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	header := []string{"name", "step", "n", "mean", "variance", "min", "max"}
+	for _, p := range probabilities {
+		header = append(header, "q"+strconv.FormatFloat(p, 'g', -1, 64))
+	}
+	writer.Write(header)
+	for _, s := range summaries {
+		line := []string{s.Name, strconv.FormatInt(s.Step, 10), strconv.Itoa(s.N),
+			cast.ToString(s.Mean), cast.ToString(s.Variance), cast.ToString(s.Min), cast.ToString(s.Max)}
+		for _, q := range s.Quantiles {
+			line = append(line, cast.ToString(q))
+		}
+		writer.Write(line)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
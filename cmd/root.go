@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 
 	"github.com/dacb/goabe/logger"
+	"github.com/dacb/goabe/plugins"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -17,6 +21,23 @@ var cfgFile string
 // the number of concurrent processes (threads) to try to use (from cobra)
 var Threads int
 
+// key=value configuration overrides given with --set (from cobra)
+var configSets []string
+
+// the name of the profile in the configuration file to apply (from cobra)
+var configProfile string
+
+// the random seed, overriding random_seed (from cobra)
+var seedFlag string
+
+// the prefix of the environment variables read into the configuration,
+// e.g., GOABE_LIFE_X_SIZE for life.x_size
+const envPrefix = "goabe"
+
+// the # of an instance's section is replaced too since it cannot be in the
+// name of a variable, e.g., GOABE_LIFE_A_X_SIZE for life#a.x_size
+var envKeyReplacer = strings.NewReplacer(".", "_", "#", "_")
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "goabe",
@@ -27,10 +48,22 @@ and analysis of agent based computations.`,
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
 	PersistentPreRun: func(cmd *cobra.Command, args []string) {
-		cmd.SetContext(context.WithValue(cmd.Context(), "threads", Threads))
+		initConfig(cmd)
+		ctx := context.WithValue(cmd.Context(), "threads", Threads)
+		// the seed is chosen once by the engine and passed to the plugins
+		seed, err := resolveSeed(seedFlag)
+		if err != nil {
+			panic(err)
+		}
+		ctx = context.WithValue(ctx, "random_seed", seed)
+		cmd.SetContext(ctx)
 	},
 }
 
+// outputAnnotation marks commands that write their results to stdout;
+// for these the console log goes to stderr and the log file is not opened.
+const outputAnnotation = "goabe/output"
+
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
 func Execute() {
@@ -42,13 +75,14 @@ func Execute() {
 
 //go:generate make registerPlugins.go
 func init() {
-	cobra.OnInitialize(initConfig)
-
 	// Here you will define your flags and configuration settings.
 	// Cobra supports persistent flags, which, if defined here,
 	// will be global for your application.
-	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default is ./goabe.json)")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file in json, yaml or toml (default is ./goabe.json, .yaml or .toml)")
+	rootCmd.PersistentFlags().StringVar(&configProfile, "profile", "", "apply the named profile from the profiles section of the config file")
 	rootCmd.PersistentFlags().IntVar(&Threads, "threads", 1, "concurrent threads (default is 1)")
+	rootCmd.PersistentFlags().StringVar(&seedFlag, "seed", "", "random seed, an integer or 'random' (default is random_seed)")
+	rootCmd.PersistentFlags().StringArrayVar(&configSets, "set", nil, "override a configuration value, e.g. --set life.x_size=64 (repeatable)")
 
 	// Cobra also supports local flags, which will only run
 	// when this action is called directly.
@@ -56,6 +90,51 @@ func init() {
 
 	// register the plugins
 	registerPlugins()
+
+	// SIGHUP rereads the log levels the way the configuration was read
+	logger.ReadConfig = rereadConfig
+}
+
+// readProfiledConfig reads a configuration file with the files it extends
+// and applies the --profile, if any.
+func readProfiledConfig(filename string) (map[string]any, error) {
+	settings, err := readConfigFile(filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	if configProfile != "" {
+		if err := applyProfile(settings, configProfile); err != nil {
+			return nil, err
+		}
+	}
+	delete(settings, "profiles")
+	return settings, nil
+}
+
+// rereadConfig reads the configuration file again into a new viper
+// instance the way initConfig did, with the files it extends, the profile,
+// the environment and the --set overrides, so a running engine can pick up
+// the changes to the file without the rest of its configuration changing.
+func rereadConfig() (*viper.Viper, error) {
+	v := viper.New()
+	v.SetEnvPrefix(envPrefix)
+	v.SetEnvKeyReplacer(envKeyReplacer)
+	v.AutomaticEnv()
+	settings, err := readProfiledConfig(viper.ConfigFileUsed())
+	if err != nil {
+		return nil, err
+	}
+	if err := v.MergeConfigMap(settings); err != nil {
+		return nil, err
+	}
+	overrides, err := parseConfigSets(configSets)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range overrides {
+		v.Set(key, value)
+	}
+	return v, nil
 }
 
 // initConfig sets default config values and reads in config from input, if possible.
@@ -63,31 +142,67 @@ func init() {
 // function will try to read the config from that file.  If not, it will
 // try to find the config file in the current directory (i.e., goabe.json). This
 // may need to change in the future to use a different default or even a URL.
-func initConfig() {
-	if cfgFile != "" {
+// The file may be in any format that viper supports, may extend other files
+// (see readConfigFile) and may contain named profiles that are applied with
+// --profile.
+// This runs before every command, with cmd being the command that was
+// invoked.
+func initConfig(cmd *cobra.Command) {
+	filename := cfgFile
+	if filename != "" {
 		// use config file specified by command line flag
-		viper.SetConfigFile(cfgFile)
+		viper.SetConfigFile(filename)
 	} else {
-		// search for config file in current directory w/ name goabe.json
-		viper.AddConfigPath(".")
-		viper.SetConfigType("json")
-		viper.SetConfigName("goabe")
+		// search for config file in current directory w/ name goabe and
+		// any of the supported extensions, e.g., goabe.json or goabe.yaml
+		filename = findConfigFile(".", "goabe")
 	}
 
-	// read in environment variables
+	// read in environment variables, GOABE_ followed by the key in upper
+	// case with . and # replaced by _
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(envKeyReplacer)
 	viper.AutomaticEnv()
 
-	// if a config file is found, read it in
+	// if a config file is found, read it in merged with the files it
+	// extends and the profile; the file is not read by viper itself so
+	// that extends and profiles, which are resolved here, are not part of
+	// the effective configuration
 	configFromFile := false
-	if err := viper.ReadInConfig(); err == nil {
+	if info, err := os.Stat(filename); err == nil && !info.IsDir() {
 		configFromFile = true
+		viper.SetConfigFile(filename)
+		settings, err := readProfiledConfig(filename)
+		if err != nil {
+			panic(err)
+		}
+		if err := viper.MergeConfigMap(settings); err != nil {
+			panic(err)
+		}
+	} else if configProfile != "" {
+		panic(fmt.Errorf("profile '%s' given but there is no configuration file", configProfile))
+	}
+
+	// the command line overrides take precedence over everything else
+	overrides, err := parseConfigSets(configSets)
+	if err != nil {
+		panic(err)
+	}
+	for key, value := range overrides {
+		viper.Set(key, value)
+		configOverrides[key] = true
+	}
+	// --seed stands for random_seed, so it is shown as set by a flag too
+	if cmd.Flags().Changed("seed") {
+		viper.Set("random_seed", seedFlag)
+		configOverrides["random_seed"] = true
 	}
 
 	// set up the logger, this has to be done after the config is read in because
 	// it contains the name of the log output
-	logger.InitLogger()
+	logger.InitLogger(cmd.Annotations[outputAnnotation] != "")
 	if configFromFile {
-		logger.Log.With("config_file", viper.ConfigFileUsed()).Info("loaded config from file")
+		logger.Log.With("config_file", viper.ConfigFileUsed()).With("profile", configProfile).Info("loaded config from file")
 	} else {
 		if cfgFile != "" {
 			logger.Log.With("config_file", viper.ConfigFileUsed()).Error("unable to read configuration file")
@@ -95,5 +210,73 @@ func initConfig() {
 		}
 		logger.Log.Info("no configuration file found and/or specified; using defaults")
 	}
+	if len(overrides) > 0 {
+		logger.Log.With("overrides", overrides).Info("configuration overridden from the command line")
+	}
+
+	// replace the default plugin instances with those configured, e.g.,
+	// life#a and life#b, now that their sections can default to the
+	// configured values of their plugins
+	if err := plugins.Instantiate(viper.GetStringSlice("plugins")); err != nil {
+		logger.Log.Error("unable to create the configured plugin instances")
+		panic(err)
+	}
 	logger.Log.Info(fmt.Sprintf("using %d threads", Threads))
 }
+
+// parseConfigSets converts key=value strings from --set into a map of
+// overrides.  Values are decoded as JSON if possible, so numbers, booleans,
+// lists and objects keep their types, and are otherwise taken as strings.
+func parseConfigSets(sets []string) (map[string]any, error) {
+	overrides := map[string]any{}
+	for _, set := range sets {
+		key, text, ok := strings.Cut(set, "=")
+		key = strings.ToLower(strings.TrimSpace(key))
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid --set '%s', expecting key=value", set)
+		}
+		overrides[key] = parseConfigValue(text)
+	}
+	return overrides, nil
+}
+
+// parseConfigValue decodes text as JSON, or returns it as a string if it is
+// not JSON.  Integers are kept exactly as int64 rather than being rounded to
+// float64, e.g., a seed such as 9007199254740993.
+func parseConfigValue(text string) any {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return text
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		// something other than space follows the value
+		return text
+	}
+	return exactNumbers(value)
+}
+
+// exactNumbers replaces the json.Numbers in a decoded value with int64s,
+// or with float64s for those that are not integers or do not fit
+func exactNumbers(value any) any {
+	switch v := value.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+		return v.String()
+	case map[string]any:
+		for key, item := range v {
+			v[key] = exactNumbers(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = exactNumbers(item)
+		}
+	}
+	return value
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestParseConfigSetsKeepsIntegers(t *testing.T) {
+	overrides, err := parseConfigSets([]string{
+		"random_seed=9007199254740993",
+		"life.x_size=12345678901234567",
+		"step_duration=0.25",
+		"log_levels={\"life\": \"DEBUG\", \"sizes\": [1, 9007199254740993]}",
+		"run_id=12 monkeys",
+		"log_file_compress=true",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]any{
+		"random_seed":       int64(9007199254740993),
+		"life.x_size":       int64(12345678901234567),
+		"step_duration":     0.25,
+		"run_id":            "12 monkeys",
+		"log_file_compress": true,
+	} {
+		if overrides[key] != want {
+			t.Errorf("%s is %#v, want %#v", key, overrides[key], want)
+		}
+	}
+	levels := fmt.Sprintf("%#v", overrides["log_levels"].(map[string]any)["sizes"])
+	if levels != "[]interface {}{1, 9007199254740993}" {
+		t.Errorf("a list in a map is %s, want its integers exact", levels)
+	}
+
+	if _, err := parseConfigSets([]string{"=1"}); err == nil {
+		t.Error("a --set without a key was accepted")
+	}
+}
+
+func TestRereadConfigLikeStartUp(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base.json":  `{"log_level": "DEBUG", "log_levels": {"life": "WARN"}}`,
+		"goabe.json": `{"extends": "base.json", "profiles": {"quiet": {"log_level": "ERROR"}}}`,
+	})
+	savedProfile, savedSets := configProfile, configSets
+	t.Cleanup(func() {
+		configProfile, configSets = savedProfile, savedSets
+		viper.SetConfigFile("")
+	})
+	viper.SetConfigFile(filepath.Join(dir, "goabe.json"))
+	configProfile = "quiet"
+	configSets = []string{"log_levels.bus=INFO"}
+	t.Setenv("GOABE_LOG_CONSOLE_LEVEL", "WARN")
+
+	v, err := rereadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]string{
+		"log_level":         "ERROR",
+		"log_levels.life":   "WARN",
+		"log_levels.bus":    "INFO",
+		"log_console_level": "WARN",
+	} {
+		if got := v.GetString(key); got != want {
+			t.Errorf("%s is %s, want %s", key, got, want)
+		}
+	}
+	if v.IsSet("extends") || v.IsSet("profiles") {
+		t.Error("the extends or profiles of the file were kept")
+	}
+}
+
+func TestEnvironmentSetsInstanceSections(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"goabe.json": `{"plugins": ["life#a", "life#b"]}`,
+	})
+	t.Setenv("GOABE_LIFE_A_X_SIZE", "48")
+	output := goabe(t, dir, "config", "show")
+	if line := lineOf(output, "life#a.x_size"); !strings.Contains(line, "48") || !strings.Contains(line, "(env)") {
+		t.Errorf("life#a.x_size is not 48 from the environment:\n%s", output)
+	}
+	if strings.Contains(lineOf(output, "life#b.x_size"), "(env)") {
+		t.Errorf("the variable of life#a set life#b:\n%s", output)
+	}
+}
+
+// lineOf returns the first line of output that starts with prefix
+func lineOf(output, prefix string) string {
+	for _, line := range strings.Split(output, "\n") {
+		if strings.HasPrefix(line, prefix) {
+			return line
+		}
+	}
+	return ""
+}
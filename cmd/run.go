@@ -4,11 +4,19 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"os"
+	"sort"
 	"sync"
 	"time"
 
+	"github.com/dacb/goabe/buildinfo"
+	"github.com/dacb/goabe/events"
+	"github.com/dacb/goabe/exchange"
 	"github.com/dacb/goabe/logger"
+	"github.com/dacb/goabe/manifest"
+	"github.com/dacb/goabe/metrics"
 	"github.com/dacb/goabe/plugins"
+	"github.com/dacb/goabe/tui"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -16,6 +24,15 @@ import (
 
 var runSteps int64
 
+// render a terminal dashboard instead of writing the console log (from cobra)
+var runTUI bool
+
+// the dashboard when running with --tui, nil otherwise
+var dash *tui.Dashboard
+
+// the recorder of the metrics published by plugins, nil if metrics_file is empty
+var recorder *metrics.Recorder
+
 // runCmd represents the run command
 var runCmd = &cobra.Command{
 	Use:   "run",
@@ -36,14 +53,81 @@ them`,
 		log.Info("run command called")
 		ctx := context.WithValue(cmd.Context(), "log", log)
 
-		err := plugins.LoadPlugins(ctx)
+		if err := checkConfig(log); err != nil {
+			panic(err)
+		}
+
+		// the seed was chosen by the engine before the command ran; record
+		// it so the effective configuration shows the seed actually used
+		seed := ctx.Value("random_seed").(int64)
+		viper.Set("random_seed", seed)
+		log.With("random_seed", seed).Info("using random seed")
+		record := newManifest(seed)
+
+		if runTUI {
+			// the dashboard takes over the terminal, the console log is
+			// shown in its log pane and plugins draw into its panels
+			dash = tui.New(os.Stdout, "goabe run", runSteps, Threads, 100*time.Millisecond)
+			restore := logger.SetConsoleWriter(dash)
+			dash.Start()
+			defer func() {
+				dash.Stop()
+				restore()
+			}()
+			ctx = context.WithValue(ctx, "display", plugins.Display(dash))
+		}
+
+		metricsFile := viper.GetString("metrics_file")
+		if metricsFile != "" {
+			var err error
+			metricsFile, err = logger.RunPath(metricsFile)
+			if err != nil {
+				panic(err)
+			}
+			recorder, err = metrics.NewRecorder(metricsFile)
+			if err != nil {
+				log.Error(fmt.Sprintf("unable to create the metrics file '%s'", metricsFile))
+				panic(err)
+			}
+			ctx = context.WithValue(ctx, "metrics", plugins.Metrics(recorder))
+		}
+
+		// the data registry and message bus shared by the plugins
+		ctx = context.WithValue(ctx, "data", exchange.NewRegistry())
+		ctx = context.WithValue(ctx, "bus", exchange.NewBus(Threads, viper.GetInt("substeps")))
+		// the discrete events, in simulation time from 0
+		ctx = context.WithValue(ctx, "events", events.NewScheduler(Threads, 0))
+
+		engine := plugins.NewEngine()
+		err := plugins.LoadPlugins(ctx, engine)
 		if err != nil {
 			log.Error("an error occurred loading the plugins")
 			panic(err)
 		}
-		setupPluginHooks(ctx)
+		if err := setupPluginHooks(engine, viper.GetInt("substeps")); err != nil {
+			log.Error("a plugin has a hook that would never run")
+			panic(err)
+		}
 
-		runCore(ctx, Threads)
+		runCore(ctx, engine, Threads)
+		if err := plugins.ShutdownPlugins(ctx, engine); err != nil {
+			log.Error("an error occurred shutting down the plugins")
+			panic(err)
+		}
+
+		// the outputs are complete once the metrics are flushed
+		if recorder != nil {
+			if err := recorder.Close(); err != nil {
+				log.Error(fmt.Sprintf("unable to write the metrics file '%s'", metricsFile))
+				panic(err)
+			}
+		}
+		record.EndTime = time.Now()
+		record.Outputs = checksumFiles(log, plugins.OutputFileKeys(), map[string]string{"metrics_file": metricsFile})
+		if err := writeManifest(record); err != nil {
+			log.Error(fmt.Sprintf("unable to write the run manifest: %v", err))
+			panic(err)
+		}
 	},
 }
 
@@ -60,6 +144,7 @@ func init() {
 	// is called directly, e.g.:
 	// runCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 	runCmd.Flags().Int64VarP(&runSteps, "steps", "", 0, "Number of steps to run the engine")
+	runCmd.Flags().BoolVar(&runTUI, "tui", false, "Show a terminal dashboard with progress, threads, plugin panels and the log")
 }
 
 //go:generate stringer -type=engineMsg
@@ -72,30 +157,118 @@ const (
 
 var pluginHooks map[int][]plugins.Hook
 
-func setupPluginHooks(ctx context.Context) {
+// setupPluginHooks groups the hooks of the plugins by substep.  A hook at a
+// substep that a step does not have, e.g., a stage of agents.Staged past
+// the last substep, is an error rather than silently never running.
+func setupPluginHooks(engine *plugins.Engine, subSteps int) error {
 	pluginHooks = make(map[int][]plugins.Hook)
-	for _, plugin := range plugins.LoadedPlugins {
+	for _, plugin := range engine.Plugins {
 		hooks := plugin.GetHooks()
 		for _, hook := range hooks {
 			subStep := hook.SubStep
+			if subStep < 0 || subStep >= subSteps {
+				return fmt.Errorf("plugin '%s' has the hook '%s' at substep %d, but the substeps are 0 to %d; increase substeps",
+					plugin.Name(), hook.Description, subStep, subSteps-1)
+			}
 			pluginHooks[subStep] = append(pluginHooks[subStep], hook)
 		}
 	}
+	return nil
 }
 
-func runCore(ctx context.Context, threads int) {
+// newManifest starts the record of a run with what is known before it
+// starts: the build, plugins, configuration, seed and inputs.
+func newManifest(seed int64) *manifest.Manifest {
+	record := &manifest.Manifest{
+		RunID:       logger.RunID,
+		Build:       buildinfo.Read(),
+		Seed:        seed,
+		Threads:     Threads,
+		Steps:       runSteps,
+		ConfigFile:  viper.ConfigFileUsed(),
+		Config:      viper.AllSettings(),
+		CommandLine: os.Args,
+		StartTime:   time.Now(),
+	}
+	record.Host, _ = os.Hostname()
+	record.WorkDir, _ = os.Getwd()
+	record.Plugins = pluginVersions()
+	inputs := map[string]string{}
+	if record.ConfigFile != "" {
+		inputs["config"] = record.ConfigFile
+	}
+	record.Inputs = checksumFiles(logger.Log, plugins.InputFileKeys(), inputs)
+	return record
+}
+
+// checksumFiles returns the checksums of the files named by the config keys
+// and of the extra files, keyed by a description, that are not empty.  A
+// file that cannot be read is logged and left out.
+func checksumFiles(log *slog.Logger, keys []string, extra map[string]string) []manifest.File {
+	files := map[string]string{}
+	for _, key := range keys {
+		files[key] = viper.GetString(key)
+	}
+	for key, filename := range extra {
+		files[key] = filename
+	}
+	names := make([]string, 0, len(files))
+	for key := range files {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	var checksums []manifest.File
+	for _, key := range names {
+		if files[key] == "" {
+			continue
+		}
+		file, err := manifest.Checksum(key, files[key])
+		if err != nil {
+			log.With("key", key).Warn(fmt.Sprintf("unable to checksum '%s' for the manifest: %v", files[key], err))
+			continue
+		}
+		checksums = append(checksums, file)
+	}
+	return checksums
+}
+
+// writeManifest saves the record of the run to manifest_file, in the run
+// directory if there is one
+func writeManifest(record *manifest.Manifest) error {
+	filename := viper.GetString("manifest_file")
+	if filename == "" {
+		return nil
+	}
+	filename, err := logger.RunPath(filename)
+	if err != nil {
+		return err
+	}
+	logger.Log.With("manifest_file", filename).Info("writing run manifest")
+	return record.Write(filename)
+}
+
+func runCore(ctx context.Context, engine *plugins.Engine, threads int) {
 	// this is the logger from the command context
 	log := ctx.Value("log").(*slog.Logger)
 
-	for _, plugin := range plugins.LoadedPlugins {
+	for _, plugin := range engine.Plugins {
 		err := plugin.PreRun(ctx)
 		if err != nil {
-			log.Error("an error occurred while trying to run the PreRun function for the '%s' plugin", plugin.Name())
+			log.Error(fmt.Sprintf("an error occurred while trying to run the PreRun function for the '%s' plugin", plugin.Name()))
 			panic(err)
 		}
 	}
 
+	// what the plugins published or sent before the first step is visible
+	// in its first substep
+	data := ctx.Value("data").(*exchange.Registry)
+	bus := ctx.Value("bus").(*exchange.Bus)
+	data.Commit(-1)
+	bus.Begin()
+	scheduler := ctx.Value("events").(*events.Scheduler)
+
 	subSteps := viper.GetInt("substeps")
+	stepDuration := viper.GetFloat64("step_duration")
 	// this waitgroup is used to signal the close of the threads
 	wgThreadsDone := new(sync.WaitGroup)
 	wgThreadsDone.Add(threads)
@@ -124,6 +297,9 @@ func runCore(ctx context.Context, threads int) {
 	for step := int64(0); step < runSteps; step++ {
 		//logger.Log.With("cmd", "run").With("actor", "core").
 		//	With("step", step).Debug("starting")
+		// hooks log with the step so the log can be filtered by step
+		stepLog := log.With("step", step)
+		stepCtx := context.WithValue(ctx, "log", stepLog)
 		for subStep := 0; subStep < subSteps; subStep++ {
 			//logger.Log.With("cmd", "run").With("actor", "core").
 			//	With("step", step).With("substep", subStep).
@@ -142,7 +318,7 @@ func runCore(ctx context.Context, threads int) {
 				hooks := pluginHooks[subStep]
 				for _, hook := range hooks {
 					if hook.Core != nil {
-						err := hook.Core(ctx)
+						err := hook.Core(stepCtx)
 						if err != nil {
 							// can this be made to report the plug in as well?
 							log.Error(fmt.Sprintf("error occurred calling plugin hook %s", hook.Description))
@@ -151,10 +327,30 @@ func runCore(ctx context.Context, threads int) {
 					}
 				}
 			}
+			// run the events due before the end of the substep
+			end := (float64(step) + float64(subStep+1)/float64(subSteps)) * stepDuration
+			if ran, err := scheduler.RunUntil(stepCtx, end); err != nil {
+				log.Error(fmt.Sprintf("error occurred running a scheduled event: %v", err))
+				panic(err)
+			} else if ran > 0 {
+				stepLog.Debug(fmt.Sprintf("ran %d events up to time %v", ran, end))
+			}
+			// what was published or sent in the substep is now visible
+			data.Commit(step)
+			bus.Deliver(step, subStep)
 			if subStep == subSteps-1 {
 				// do atomic stuff at end of step
 				runTime := time.Now().Sub(stepStartTime)
-				log.With("step", step).With("run_time", runTime).Info("finished")
+				stepLog.With("run_time", runTime).Info("finished")
+				if dash != nil {
+					dash.StepDone(step)
+				}
+				if recorder != nil {
+					if err := recorder.EndStep(step); err != nil {
+						log.Error("unable to write the step's metrics")
+						panic(err)
+					}
+				}
 				stepStartTime = time.Now()
 			}
 
@@ -167,16 +363,19 @@ func runCore(ctx context.Context, threads int) {
 	// report time
 	runTime := time.Now().Sub(runStartTime)
 	log.With("run_time", runTime).Info("finished")
+	if pending := scheduler.Len(); pending > 0 {
+		log.Info(fmt.Sprintf("%d scheduled events were not due by the end of the run", pending))
+	}
 	// wait until the threads are done
 	log.Debug("waiting for threads")
 	wgThreadsDone.Wait()
 	log.Debug("done")
 
 	// call the plugin postruns
-	for _, plugin := range plugins.LoadedPlugins {
+	for _, plugin := range engine.Plugins {
 		err := plugin.PostRun(ctx)
 		if err != nil {
-			log.Error("an error occurred while trying to run the PostRun function for the '%s' plugin", plugin.Name())
+			log.Error(fmt.Sprintf("an error occurred while trying to run the PostRun function for the '%s' plugin", plugin.Name()))
 			panic(err)
 		}
 	}
@@ -185,6 +384,16 @@ func runCore(ctx context.Context, threads int) {
 
 func runThread(ctx context.Context, wgDone *sync.WaitGroup, syncChan chan engineMsg, name string, id int) {
 	defer wgDone.Done()
+	defer func() {
+		// a panic here ends the program without running the run command's
+		// deferred calls, so give the terminal back from the dashboard first
+		if r := recover(); r != nil {
+			if dash != nil {
+				dash.Stop()
+			}
+			panic(r)
+		}
+	}()
 	log := ctx.Value("log").(*slog.Logger)
 	log.Debug("started")
 
@@ -196,16 +405,21 @@ func runThread(ctx context.Context, wgDone *sync.WaitGroup, syncChan chan engine
 	for step := int64(0); step < runSteps && state != HALT; step++ {
 		//logger.Log.With("cmd", "run").With("actor", name).
 		//	With("step", step).Debug("starting")
+		stepCtx := context.WithValue(ctx, "log", log.With("step", step))
 		for subStep := 0; subStep < subSteps && state != HALT; subStep++ {
 			//logger.Log.With("cmd", "run").With("actor", name).
 			//	With("step", step).With("substep", subStep).
 			//	With("workTimeMS", workTimeMS).Debug("working")
 			{
+				var workStart time.Time
+				if dash != nil {
+					workStart = time.Now()
+				}
 				hooks := pluginHooks[subStep]
 				for _, hook := range hooks {
 					if hook.Thread != nil {
 						// make the thread call for this substep
-						err := hook.Thread(ctx, id, name)
+						err := hook.Thread(stepCtx, id, name)
 						if err != nil {
 							// can this be made to report the plug in as well?
 							log.Error(fmt.Sprintf("error occurred calling plugin hook %s", hook.Description))
@@ -213,6 +427,9 @@ func runThread(ctx context.Context, wgDone *sync.WaitGroup, syncChan chan engine
 						}
 					}
 				}
+				if dash != nil {
+					dash.ThreadBusy(id, time.Since(workStart))
+				}
 			}
 			// send back our message that we are ready to continue
 			syncChan <- CONTINUE
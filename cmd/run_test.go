@@ -0,0 +1,17 @@
+package cmd
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRunRejectsHooksPastTheSubsteps(t *testing.T) {
+	dir := t.TempDir()
+	// life updates its cells in substep 1
+	writeFiles(t, dir, map[string]string{
+		"goabe.json": `{"substeps": 1, "log_file": "", "manifest_file": "", "metrics_file": "", "plugins": ["life"], "life": {"x_size": 4, "y_size": 4}}`,
+	})
+	if output := goabeFails(t, dir, "run", "--steps", "1"); !strings.Contains(output, "at substep 1, but the substeps are 0 to 0") {
+		t.Errorf("a hook past the last substep was not rejected:\n%s", output)
+	}
+}
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// the value of random_seed that asks for a new seed for each run
+const randomSeed = "random"
+
+// resolveSeed returns the seed a run will use: flagSeed if it was given on
+// the command line, otherwise random_seed from the configuration, where
+// "random" (the default) draws a new seed from the operating system.  The
+// effective seed is logged and recorded in the manifest so that any run can
+// be reproduced with --seed.
+func resolveSeed(flagSeed string) (int64, error) {
+	// a seed that was read as a float64 may have been rounded
+	if value, ok := viper.Get("random_seed").(float64); ok && math.Abs(value) > 1<<53 {
+		return 0, fmt.Errorf("random seed %v cannot be read exactly, give it as a string", value)
+	}
+	text := viper.GetString("random_seed")
+	if flagSeed != "" {
+		text = flagSeed
+	}
+	text = strings.TrimSpace(text)
+	if text == "" || strings.EqualFold(text, randomSeed) {
+		var buf [8]byte
+		if _, err := rand.Read(buf[:]); err != nil {
+			return 0, err
+		}
+		// keep it positive so it is easy to read and to pass back in
+		return int64(binary.LittleEndian.Uint64(buf[:]) >> 1), nil
+	}
+	seed, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid random seed '%s', expecting an integer or '%s'", text, randomSeed)
+	}
+	return seed, nil
+}
+
+// deriveSeed returns the seed of the i-th of a set of runs made from base,
+// e.g., replicates.  The seeds are well mixed (splitmix64) so neighbouring
+// runs do not get correlated random streams, and are positive.
+func deriveSeed(base int64, i int) int64 {
+	z := uint64(base) + uint64(i+1)*0x9e3779b97f4a7c15
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	z = z ^ (z >> 31)
+	return int64(z >> 1)
+}
@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSeedFromConfigIsExact(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base.json":  `{"random_seed": 9007199254740993, "life": {"x_size": 4, "y_size": 4}}`,
+		"goabe.json": `{"extends": "base.json", "log_file": "", "manifest_file": "", "metrics_file": "", "plugins": ["life"]}`,
+	})
+	output := goabe(t, dir, "run", "--steps", "1")
+	if !strings.Contains(output, "random_seed=9007199254740993") {
+		t.Errorf("the seed was not used exactly:\n%s", output)
+	}
+
+	settings, err := readConfigFile(filepath.Join(dir, "base.json"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if settings["random_seed"] != int64(9007199254740993) {
+		t.Errorf("random_seed was read as %#v", settings["random_seed"])
+	}
+}
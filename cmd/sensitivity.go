@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/dacb/goabe/batch"
+	"github.com/dacb/goabe/logger"
+	"github.com/dacb/goabe/metrics"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// the sensitivity settings that override the spec file (from cobra)
+var sensitivitySteps int64
+var sensitivityParallel int
+var sensitivityOutput string
+var sensitivitySamples int
+var sensitivitySeed int64
+
+// sensitivityCmd represents the sensitivity command
+var sensitivityCmd = &cobra.Command{
+	Use:   "sensitivity <spec file>",
+	Short: "Estimate the Sobol sensitivity indices of model outputs",
+	Long: `Samples the parameter ranges in the spec, runs the model for each sample
+and estimates the first order and total Sobol indices of each output, e.g.,
+
+  sampling: lhs            # Latin hypercube, or saltelli for a Sobol sequence
+  samples: 64
+  seed: 1                  # seed of the sampling design
+  steps: 100
+  statistic: final         # the output is the metric's final value, or mean
+  outputs: [life.alive_cells]
+  parameters:
+    life.x_size: {min: 8, max: 64, type: int}
+    life.y_size: {min: 8, max: 64, type: int}
+
+The outputs are metrics published by plugins.  The design takes
+samples*(parameters+2) runs, each a separate goabe process in its own
+directory under the output directory.  Every run uses the same model seed,
+unless random_seed is one of the parameters, so the indices measure the
+effect of the parameters rather than of the random stream.
+
+The results are samples.csv, with the parameters and outputs of each run,
+and sobol.csv, with the indices of each output and parameter.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		log := logger.Log.With(
+			slog.Group("cmd",
+				slog.String("cmd", "sensitivity"),
+				slog.String("spec", args[0]),
+			),
+		)
+		design, err := batch.ReadDesign(args[0])
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("steps") || design.Steps == 0 {
+			design.Steps = sensitivitySteps
+		}
+		if cmd.Flags().Changed("parallel") || design.Parallel == 0 {
+			design.Parallel = sensitivityParallel
+		}
+		if cmd.Flags().Changed("output") || design.OutputDir == "" {
+			design.OutputDir = sensitivityOutput
+		}
+		if cmd.Flags().Changed("samples") || design.Samples == 0 {
+			design.Samples = sensitivitySamples
+		}
+		if cmd.Flags().Changed("design-seed") {
+			design.Seed = sensitivitySeed
+		}
+		if viper.GetString("metrics_file") == "" {
+			return fmt.Errorf("metrics_file must be set to collect the model outputs")
+		}
+		runs, err := design.Runs()
+		if err != nil {
+			return err
+		}
+
+		runner, err := newBatchRunner(cmd, design.OutputDir, design.Steps, design.Parallel, log)
+		if err != nil {
+			return err
+		}
+		// common random numbers across the samples
+		runner.Base["random_seed"] = cmd.Context().Value("random_seed").(int64)
+		log.Info(fmt.Sprintf("running %d %s samples of %d parameters, %d runs of %d steps, into %s",
+			design.Samples, design.Sampling, len(design.Parameters), len(runs), design.Steps, design.OutputDir))
+		results := runner.Execute(cmd.Context(), runs)
+		index := filepath.Join(design.OutputDir, "index.csv")
+		if err := writeBatchIndex(index, results); err != nil {
+			return err
+		}
+		if err := batchFailures(results); err != nil {
+			return err
+		}
+
+		// outputs[o][r] is output o of run r
+		outputs := make([][]float64, len(design.Outputs))
+		for r, result := range results {
+			series, err := metrics.ReadFile(filepath.Join(result.Dir, viper.GetString("metrics_file")))
+			if err != nil {
+				return err
+			}
+			for o, name := range design.Outputs {
+				value, ok := series.Final(name)
+				if design.Statistic == "mean" {
+					value, ok = series.Mean(name)
+				}
+				if !ok {
+					return fmt.Errorf("run %s did not publish the metric %s", result.ID, name)
+				}
+				if r == 0 {
+					outputs[o] = make([]float64, len(results))
+				}
+				outputs[o][r] = value
+			}
+		}
+		if err := writeSamples(filepath.Join(design.OutputDir, "samples.csv"), design, results, outputs); err != nil {
+			return err
+		}
+		filename := filepath.Join(design.OutputDir, "sobol.csv")
+		if err := writeSobol(filename, design, outputs, log); err != nil {
+			return err
+		}
+		log.With("sobol", filename).Info("sensitivity analysis finished")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sensitivityCmd)
+
+	sensitivityCmd.Flags().Int64Var(&sensitivitySteps, "steps", 0, "steps for each run (default is steps in the spec)")
+	sensitivityCmd.Flags().IntVar(&sensitivityParallel, "parallel", 1, "number of runs at once (default is parallel in the spec, or 1)")
+	sensitivityCmd.Flags().StringVar(&sensitivityOutput, "output", "sensitivity", "output directory (default is output_dir in the spec, or sensitivity)")
+	sensitivityCmd.Flags().IntVar(&sensitivitySamples, "samples", 16, "rows of each base matrix (default is samples in the spec, or 16)")
+	sensitivityCmd.Flags().Int64Var(&sensitivitySeed, "design-seed", 1, "seed of the sampling design (default is seed in the spec, or 1)")
+}
+
+// writeSamples writes a CSV with the parameters and outputs of each run.
+func writeSamples(filename string, design *batch.Design, results []batch.Result, outputs [][]float64) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	header := []string{"run_id"}
+	for _, parameter := range design.Parameters {
+		header = append(header, parameter.Key)
+	}
+	writer.Write(append(header, design.Outputs...))
+	for r, result := range results {
+		line := []string{result.ID}
+		for _, parameter := range design.Parameters {
+			line = append(line, cast.ToString(result.Params[parameter.Key]))
+		}
+		for o := range design.Outputs {
+			line = append(line, strconv.FormatFloat(outputs[o][r], 'g', -1, 64))
+		}
+		writer.Write(line)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// writeSobol estimates the indices of each output and writes them as CSV
+// with one line per output and parameter.
+func writeSobol(filename string, design *batch.Design, outputs [][]float64, log *slog.Logger) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"output", "parameter", "first_order", "total"})
+	for o, name := range design.Outputs {
+		first, total := metrics.Sobol(design.Split(outputs[o]))
+		for i, parameter := range design.Parameters {
+			writer.Write([]string{name, parameter.Key,
+				strconv.FormatFloat(first[i], 'g', 6, 64),
+				strconv.FormatFloat(total[i], 'g', 6, 64),
+			})
+			log.With("output", name).With("parameter", parameter.Key).
+				With("first_order", first[i]).With("total", total[i]).Info("sobol indices")
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
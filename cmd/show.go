@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// the format to show the configuration in (from cobra)
+var showOutput string
+
+// configOverrides holds the keys set from the command line, with --set or
+// a flag such as --seed, so that their source can be reported as a flag.
+var configOverrides = map[string]bool{}
+
+// showCmd represents the show command
+var showCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the effective configuration and where each value came from",
+	Long: `Prints the fully merged configuration that a run would use, including
+the engine and plugin defaults, with the source of each value: default, file,
+env or flag.  The json and yaml outputs can be archived alongside results.`,
+	Annotations: map[string]string{outputAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		keys := configKeys()
+		sources := map[string]string{}
+		for _, key := range keys {
+			sources[key] = configSource(key)
+		}
+		// the number of threads is not part of the configuration, only a flag
+		threadsSource := "default"
+		if cmd.Flags().Changed("threads") {
+			threadsSource = "flag"
+		}
+
+		switch showOutput {
+		case "text":
+			fmt.Fprintf(out, "# config file: %s\n", viper.ConfigFileUsed())
+			fmt.Fprintf(out, "# threads: %d (%s)\n", Threads, threadsSource)
+			writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			for _, key := range keys {
+				value, err := json.Marshal(viper.Get(key))
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(writer, "%s\t%s\t(%s)\n", key, value, sources[key])
+			}
+			return writer.Flush()
+		case "json", "yaml":
+			document := map[string]any{
+				"config_file": viper.ConfigFileUsed(),
+				"config":      viper.AllSettings(),
+				"sources":     sources,
+				"threads": map[string]any{
+					"value":  Threads,
+					"source": threadsSource,
+				},
+			}
+			if showOutput == "json" {
+				encoder := json.NewEncoder(out)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(document)
+			}
+			encoder := yaml.NewEncoder(out)
+			encoder.SetIndent(2)
+			return encoder.Encode(document)
+		default:
+			return fmt.Errorf("unknown output format '%s', expecting text, json or yaml", showOutput)
+		}
+	},
+}
+
+func init() {
+	configCmd.AddCommand(showCmd)
+
+	showCmd.Flags().StringVarP(&showOutput, "output", "o", "text", "output format: text, json or yaml")
+}
+
+// configKeys returns the sorted keys of the leaf values in the configuration.
+// A map such as log_levels is listed by its entries when it has any.
+func configKeys() []string {
+	keys := viper.AllKeys()
+	sort.Strings(keys)
+	var leaves []string
+	for i, key := range keys {
+		if i+1 < len(keys) && strings.HasPrefix(keys[i+1], key+".") {
+			continue
+		}
+		leaves = append(leaves, key)
+	}
+	return leaves
+}
+
+// envKey is the name of the environment variable that viper checks for key
+func envKey(key string) string {
+	return strings.ToUpper(envPrefix + "_" + envKeyReplacer.Replace(key))
+}
+
+// cutLast splits s around the last instance of sep
+func cutLast(s, sep string) (before, after string, found bool) {
+	if i := strings.LastIndex(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return "", s, false
+}
+
+// configSource returns where the effective value of key comes from, in
+// viper's order of precedence.
+func configSource(key string) string {
+	// an override of a map sets all of its entries
+	for parent := key; parent != ""; parent, _, _ = cutLast(parent, ".") {
+		if configOverrides[parent] {
+			return "flag"
+		}
+	}
+	if value, ok := os.LookupEnv(envKey(key)); ok && value != "" {
+		return "env"
+	}
+	if viper.InConfig(key) {
+		return "file"
+	}
+	return "default"
+}
@@ -0,0 +1,37 @@
+package cmd
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestConfigShowOmitsExtendsAndProfiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"base.json":  `{"substeps": 3}`,
+		"goabe.json": `{"extends": "base.json", "profiles": {"fast": {"substeps": 1}}}`,
+	})
+	var shown struct {
+		Config  map[string]any    `json:"config"`
+		Sources map[string]string `json:"sources"`
+	}
+	// the console log of an output command goes to stderr, ahead of the document
+	output := goabe(t, dir, "--profile", "fast", "config", "show", "-o", "json")
+	if err := json.Unmarshal([]byte(output[strings.Index(output, "{\n"):]), &shown); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"extends", "profiles"} {
+		if _, ok := shown.Config[key]; ok {
+			t.Errorf("%s is in the effective configuration", key)
+		}
+		if _, ok := shown.Sources[key]; ok {
+			t.Errorf("%s has a source", key)
+		}
+	}
+	if shown.Config["substeps"] != 1.0 {
+		t.Errorf("substeps is %v, want the profile's 1", shown.Config["substeps"])
+	}
+
+	goabe(t, dir, "config", "validate", "--strict")
+}
@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"os"
+	"strconv"
+
+	"github.com/dacb/goabe/logger"
+
+	"github.com/spf13/cobra"
+)
+
+// the CSV file to write the step series to, stdout if empty (from cobra)
+var stepsCSV string
+
+// stepsCmd represents the log steps command
+var stepsCmd = &cobra.Command{
+	Use:   "steps [log file]",
+	Short: "Extract the step run time series as CSV",
+	Long: `Writes a CSV with the step number and the wall clock run time of
+the step in nanoseconds, taken from the core's end of step records.`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{outputAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		if stepsCSV != "" {
+			file, err := os.Create(stepsCSV)
+			if err != nil {
+				return err
+			}
+			defer file.Close()
+			out = file
+		}
+		writer := csv.NewWriter(out)
+		writer.Write([]string{"step", "run_time_ns"})
+		err := readLog(args, func(r *logger.Record) error {
+			if !r.IsStepEnd() {
+				return nil
+			}
+			return writer.Write([]string{
+				strconv.FormatInt(r.Step, 10),
+				strconv.FormatInt(int64(r.RunTime), 10),
+			})
+		})
+		if err != nil {
+			return err
+		}
+		writer.Flush()
+		return writer.Error()
+	},
+}
+
+func init() {
+	logCmd.AddCommand(stepsCmd)
+
+	stepsCmd.Flags().StringVarP(&stepsCSV, "csv", "o", "", "CSV file to write (default is stdout)")
+}
@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/dacb/goabe/logger"
+
+	"github.com/spf13/cobra"
+)
+
+// the number of slowest steps to report (from cobra)
+var summaryTop int
+
+// summaryCmd represents the log summary command
+var summaryCmd = &cobra.Command{
+	Use:   "summary [log file]",
+	Short: "Summarize a run from its log",
+	Long: `Reports the record counts by level, the total run time, step time
+statistics, the slowest steps and the error messages found in the log.`,
+	Args:        cobra.MaximumNArgs(1),
+	Annotations: map[string]string{outputAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		levels := map[slog.Level]int{}
+		var steps []*logger.Record
+		var runTime time.Duration
+		runEnded := false
+		var errors []*logger.Record
+		var first, last time.Time
+		err := readLog(args, func(r *logger.Record) error {
+			if first.IsZero() {
+				first = r.Time
+			}
+			last = r.Time
+			levels[r.Level] += 1
+			switch {
+			case r.IsStepEnd():
+				steps = append(steps, r)
+			case r.IsRunEnd():
+				runTime, runEnded = r.RunTime, true
+			}
+			if r.Level >= slog.LevelError {
+				errors = append(errors, r)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		out := cmd.OutOrStdout()
+		fmt.Fprintf(out, "records: %d debug, %d info, %d warn, %d error\n",
+			levels[slog.LevelDebug], levels[slog.LevelInfo], levels[slog.LevelWarn], levels[slog.LevelError])
+		fmt.Fprintf(out, "log span: %v (%s to %s)\n", last.Sub(first), first.Format(time.RFC3339), last.Format(time.RFC3339))
+		if runEnded {
+			fmt.Fprintf(out, "total run time: %v\n", runTime)
+		} else {
+			fmt.Fprintf(out, "total run time: unknown, the run did not finish\n")
+		}
+		if len(steps) > 0 {
+			var total time.Duration
+			for _, r := range steps {
+				total += r.RunTime
+			}
+			sort.SliceStable(steps, func(i, j int) bool { return steps[i].RunTime > steps[j].RunTime })
+			fmt.Fprintf(out, "steps: %d, mean %v, min %v, max %v\n",
+				len(steps), total/time.Duration(len(steps)), steps[len(steps)-1].RunTime, steps[0].RunTime)
+			fmt.Fprintf(out, "slowest steps:\n")
+			for i := 0; i < summaryTop && i < len(steps); i++ {
+				fmt.Fprintf(out, "  step %d: %v\n", steps[i].Step, steps[i].RunTime)
+			}
+		}
+		fmt.Fprintf(out, "errors: %d\n", len(errors))
+		for _, r := range errors {
+			fmt.Fprintf(out, "  %s %s\n", r.Time.Format(time.RFC3339), r.Msg)
+		}
+		return nil
+	},
+}
+
+func init() {
+	logCmd.AddCommand(summaryCmd)
+
+	summaryCmd.Flags().IntVar(&summaryTop, "top", 5, "number of slowest steps to report")
+}
@@ -0,0 +1,203 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dacb/goabe/batch"
+	"github.com/dacb/goabe/logger"
+	"github.com/dacb/goabe/plugins"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// the sweep settings that override the spec file (from cobra)
+var sweepSteps int64
+var sweepParallel int
+var sweepOutput string
+
+// sweepCmd represents the sweep command
+var sweepCmd = &cobra.Command{
+	Use:   "sweep <spec file>",
+	Short: "Run the model over a grid of parameter values",
+	Long: `Runs the configured model once for each point of the parameter grid in the
+sweep spec, e.g.,
+
+  mode: cartesian          # every combination, or zip to pair the values
+  steps: 100
+  parameters:
+    life.x_size: [16, 32, 64]
+    random_seed: {from: 1, to: 5, step: 1}
+
+Each run is a separate goabe process in its own directory under the output
+directory, with the configuration it used, its logs, its manifest and its
+output files.  The runs are spread over --parallel processes and index.csv
+maps each run id to its parameters and result files.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cmd.SilenceUsage = true
+		log := logger.Log.With(
+			slog.Group("cmd",
+				slog.String("cmd", "sweep"),
+				slog.String("spec", args[0]),
+			),
+		)
+		sweep, err := batch.ReadSweep(args[0])
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("steps") || sweep.Steps == 0 {
+			sweep.Steps = sweepSteps
+		}
+		if cmd.Flags().Changed("parallel") || sweep.Parallel == 0 {
+			sweep.Parallel = sweepParallel
+		}
+		if cmd.Flags().Changed("output") || sweep.OutputDir == "" {
+			sweep.OutputDir = sweepOutput
+		}
+		runs, err := sweep.Runs()
+		if err != nil {
+			return err
+		}
+
+		runner, err := newBatchRunner(cmd, sweep.OutputDir, sweep.Steps, sweep.Parallel, log)
+		if err != nil {
+			return err
+		}
+		log.Info(fmt.Sprintf("sweeping %d runs of %d steps, %d at a time, into %s",
+			len(runs), sweep.Steps, sweep.Parallel, sweep.OutputDir))
+		results := runner.Execute(cmd.Context(), runs)
+
+		index := filepath.Join(sweep.OutputDir, "index.csv")
+		if err := writeBatchIndex(index, results); err != nil {
+			return err
+		}
+		log.With("index", index).Info("sweep finished")
+		return batchFailures(results)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(sweepCmd)
+
+	sweepCmd.Flags().Int64Var(&sweepSteps, "steps", 0, "steps for each run (default is steps in the spec)")
+	sweepCmd.Flags().IntVar(&sweepParallel, "parallel", 1, "number of runs at once (default is parallel in the spec, or 1)")
+	sweepCmd.Flags().StringVar(&sweepOutput, "output", "sweep", "output directory (default is output_dir in the spec, or sweep)")
+}
+
+// newBatchRunner creates a runner of goabe processes based on the effective
+// configuration of this command, including --set, --profile and --seed.
+func newBatchRunner(cmd *cobra.Command, outputDir string, steps int64, parallel int, log *slog.Logger) (*batch.Runner, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(outputDir, 0777); err != nil {
+		return nil, err
+	}
+	// the effective configuration is already merged, so the runs must not
+	// extend files relative to their own directories again; relative input
+	// paths are made absolute by the runner and relative output paths, such
+	// as log_file, are meant to land in each run's directory
+	base := viper.AllSettings()
+	delete(base, "extends")
+	delete(base, "profiles")
+	if seedFlag != "" {
+		base["random_seed"] = cmd.Context().Value("random_seed").(int64)
+	}
+	runner := &batch.Runner{
+		Executable: executable,
+		Base:       base,
+		InputKeys:  plugins.InputFileKeys(),
+		OutputKeys: append(plugins.OutputFileKeys(), "log_file", "manifest_file", "metrics_file"),
+		OutputDir:  outputDir,
+		Steps:      steps,
+		Threads:    Threads,
+		Parallel:   parallel,
+		Done: func(result batch.Result) {
+			if result.Err != nil {
+				log.With("run", result.ID).Error(result.Err.Error())
+				return
+			}
+			log.With("run", result.ID).With("run_time", result.Duration).Info("run finished")
+		},
+	}
+	// check the base configuration before any run is started
+	if _, err := runner.Config(batch.Run{}); err != nil {
+		return nil, err
+	}
+	return runner, nil
+}
+
+// writeBatchIndex writes a CSV with one line per run: its id, parameters,
+// status and files.
+func writeBatchIndex(filename string, results []batch.Result) error {
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	runs := make([]batch.Run, len(results))
+	for i := range results {
+		runs[i] = results[i].Run
+	}
+	keys := batch.ParamKeys(runs)
+	writer := csv.NewWriter(file)
+	header := append([]string{"run_id"}, keys...)
+	header = append(header, "status", "error", "run_time_s", "dir", "config", "log_file", "manifest_file", "files")
+	writer.Write(header)
+	for _, result := range results {
+		line := []string{result.ID}
+		for _, key := range keys {
+			line = append(line, cast.ToString(result.Params[key]))
+		}
+		status, message := "ok", ""
+		if result.Err != nil {
+			status, message = "failed", result.Err.Error()
+		}
+		line = append(line, status, message,
+			fmt.Sprintf("%.3f", result.Duration.Seconds()),
+			result.Dir,
+			filepath.Join(result.Dir, batch.ConfigName),
+			runFile(result.Dir, viper.GetString("log_file")),
+			runFile(result.Dir, viper.GetString("manifest_file")),
+			strings.Join(result.Files, ";"),
+		)
+		writer.Write(line)
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// batchFailures returns an error if any of the runs failed
+func batchFailures(results []batch.Result) error {
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed += 1
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d runs failed", failed, len(results))
+	}
+	return nil
+}
+
+// runFile is the path of a file written by a run, or empty if it is not
+// written
+func runFile(dir, filename string) string {
+	if filename == "" {
+		return ""
+	}
+	return filepath.Join(dir, filename)
+}
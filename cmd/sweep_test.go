@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSweepFromExtendingConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"common/base.json": `{"life": {"x_size": 8, "y_size": 8}, "substeps": 2, "profiles": {"small": {"life": {"x_size": 6}}}}`,
+		"goabe.json":       `{"extends": "common/base.json", "log_console_format": "none", "manifest_file": "", "plugins": ["life"], "plugin_dir": "models"}`,
+		"sweep.json":       `{"steps": 2, "parameters": {"random_seed": [1, 2]}}`,
+		"models/README":    "plugins",
+	})
+	goabe(t, dir, "--profile", "small", "sweep", "sweep.json")
+
+	data, err := os.ReadFile(filepath.Join(dir, "sweep", "run_1", "goabe.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config map[string]any
+	if err := json.Unmarshal(data, &config); err != nil {
+		t.Fatal(err)
+	}
+	if extends, _ := config["extends"].([]any); len(extends) != 0 {
+		t.Errorf("the run's configuration extends %v", extends)
+	}
+	if profiles, _ := config["profiles"].(map[string]any); len(profiles) != 0 {
+		t.Errorf("the run's configuration has the profiles %v", profiles)
+	}
+	if pluginDir, _ := config["plugin_dir"].(string); !filepath.IsAbs(pluginDir) || filepath.Base(pluginDir) != "models" {
+		t.Errorf("the run's plugin_dir is %s, want the absolute path of models", pluginDir)
+	}
+	life, _ := config["life"].(map[string]any)
+	if life["x_size"] != 6.0 || life["y_size"] != 8.0 || config["substeps"] != 2.0 {
+		t.Errorf("the run's configuration is not the merged one: %s", data)
+	}
+
+	file, err := os.Open(filepath.Join(dir, "sweep", "index.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	index, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, column := range index[0] {
+		if column == "manifest_file" && index[1][i] != "" {
+			t.Errorf("the index has the manifest %s, but none is written", index[1][i])
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/dacb/goabe/plugins"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// treat unknown keys as errors (from cobra)
+var validateStrict bool
+
+// validateCmd represents the validate command
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check a configuration file against the engine and plugin schemas",
+	Long: `Reports unknown keys, values of the wrong type, values out of range and
+missing input files in the configuration before a run is started.  Unknown
+keys are warnings unless --strict is given.`,
+	Annotations: map[string]string{outputAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		problems := plugins.ValidateConfig()
+		fatal := 0
+		for _, problem := range problems {
+			severity := "warning"
+			if problem.Fatal || validateStrict {
+				severity = "error"
+				fatal += 1
+			}
+			fmt.Fprintf(out, "%s: %s\n", severity, problem)
+		}
+		source := viper.ConfigFileUsed()
+		if source == "" {
+			source = "default configuration"
+		}
+		if fatal > 0 {
+			cmd.SilenceUsage = true
+			return fmt.Errorf("%s has %d errors", source, fatal)
+		}
+		fmt.Fprintf(out, "%s is valid\n", source)
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(validateCmd)
+
+	validateCmd.Flags().BoolVar(&validateStrict, "strict", false, "treat unknown keys as errors")
+}
+
+// checkConfig logs the problems with the configuration and returns an
+// error if any of them should stop a run.
+func checkConfig(log *slog.Logger) error {
+	fatal := 0
+	for _, problem := range plugins.ValidateConfig() {
+		if problem.Fatal {
+			log.With("key", problem.Key).Error(problem.Problem)
+			fatal += 1
+		} else {
+			log.With("key", problem.Key).Warn(problem.Problem)
+		}
+	}
+	if fatal > 0 {
+		return fmt.Errorf("the configuration has %d errors, see 'goabe config validate'", fatal)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/dacb/goabe/buildinfo"
+	"github.com/dacb/goabe/manifest"
+	"github.com/dacb/goabe/plugins"
+
+	"github.com/spf13/cobra"
+)
+
+// the format to print the version in (from cobra)
+var versionOutput string
+
+// versionCmd represents the version command
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version of the engine, its build and its plugins",
+	Long: `Prints the engine version, the Go version and the VCS revision the binary
+was built from, with a dirty flag if the checkout had uncommitted changes,
+and the name and version of every compiled-in plugin.  Include the output
+in bug reports.`,
+	Args:        cobra.NoArgs,
+	Annotations: map[string]string{outputAnnotation: "true"},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		out := cmd.OutOrStdout()
+		build := buildinfo.Read()
+		switch versionOutput {
+		case "text":
+			revision := build.Revision
+			if revision == "" {
+				revision = "unknown"
+			} else if build.Modified {
+				revision += " (dirty)"
+			}
+			fmt.Fprintf(out, "goabe %s\n", build.Version)
+			fmt.Fprintf(out, "go: %s\n", build.GoVersion)
+			fmt.Fprintf(out, "revision: %s\n", revision)
+			if build.RevisionTime != "" {
+				fmt.Fprintf(out, "revision time: %s\n", build.RevisionTime)
+			}
+			fmt.Fprintln(out, "plugins:")
+			writer := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+			for _, plugin := range pluginVersions() {
+				fmt.Fprintf(writer, "  %s\t%s\n", plugin.Name, plugin.Version)
+			}
+			return writer.Flush()
+		case "json":
+			encoder := json.NewEncoder(out)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(struct {
+				buildinfo.Info
+				Plugins []manifest.Plugin `json:"plugins"`
+			}{build, pluginVersions()})
+		default:
+			return fmt.Errorf("unknown output format '%s', expecting text or json", versionOutput)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(versionCmd)
+
+	versionCmd.Flags().StringVarP(&versionOutput, "output", "o", "text", "output format: text or json")
+}
+
+// pluginVersions returns the name and version of each compiled-in plugin.
+func pluginVersions() []manifest.Plugin {
+	var versions []manifest.Plugin
+	for _, plugin := range plugins.LoadedPlugins {
+		major, minor, patch := plugin.Version()
+		versions = append(versions, manifest.Plugin{
+			Name:    plugin.Name(),
+			Version: fmt.Sprintf("%d.%d.%d", major, minor, patch),
+		})
+	}
+	return versions
+}
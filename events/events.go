@@ -0,0 +1,262 @@
+// Package events schedules discrete events in continuous simulation time
+// alongside the engine's fixed steps.  The engine puts a Scheduler on the
+// context as "events".  Simulation time advances by step_duration in each
+// step, split evenly between its substeps: step k spans the times from
+// k*step_duration up to (k+1)*step_duration.  At each substep's barrier,
+// after its core hooks, the engine runs the actions of the events due
+// before the end of the substep, in time order, on the core.  An action
+// may schedule or cancel events, including ones due in the same substep.
+//
+// For example, an infection plugin schedules a recovery after a random
+// duration from a thread hook
+//
+//	scheduler := ctx.Value("events").(*events.Scheduler)
+//	scheduler.After(id, m.rng.ExpFloat64()*meanDuration, events.Event{
+//		Name:    "recovery",
+//		Action:  m.recover,
+//		Payload: agent,
+//	})
+//
+// Events due at the same time run in order of Priority, lowest first, and
+// then in the order they were queued.  Events scheduled from the core are
+// queued right away and those scheduled from thread hooks at the barrier,
+// those of thread 0 first, then thread 1 and so on, so the order does not
+// depend on how the threads are scheduled.
+package events
+
+import (
+	"container/heap"
+	"context"
+	"fmt"
+	"math"
+	"sync"
+)
+
+// Core is the thread id to schedule and cancel events with from core
+// hooks, actions, Init and PreRun.
+const Core = -1
+
+// Action is called when its event is due, with the scheduler's time set
+// to the event's time.
+type Action func(ctx context.Context, event *Event) error
+
+// Event is something that happens at a point in simulation time.
+type Event struct {
+	Time     float64 // when the event happens
+	Priority int     // the order of events at the same time, lowest first
+	Name     string  // for logging
+	Action   Action
+	Payload  any
+
+	id       ID
+	sequence uint64 // the order the event was queued, for ties
+	index    int    // position in the queue, -1 once it is not queued
+}
+
+// ID identifies a scheduled event, e.g., to cancel it.
+type ID struct {
+	thread int
+	n      uint64
+}
+
+// ID returns the event's identifier.
+func (e *Event) ID() ID {
+	return e.id
+}
+
+// Scheduler is a priority queue of events.  Its methods are safe to call
+// from any hook.
+type Scheduler struct {
+	mu       sync.Mutex
+	now      float64
+	queue    queue
+	staged   [][]*Event    // scheduled from each thread since the last barrier
+	counts   []uint64      // events scheduled from each thread, the last for the core
+	events   map[ID]*Event // scheduled events that have not run, by id
+	sequence uint64
+}
+
+// NewScheduler creates a scheduler for threads threads whose time starts
+// at start.
+func NewScheduler(threads int, start float64) *Scheduler {
+	return &Scheduler{
+		now:    start,
+		staged: make([][]*Event, threads),
+		counts: make([]uint64, threads+1),
+		events: map[ID]*Event{},
+	}
+}
+
+// Now returns the simulation time: during a substep the time at its start
+// and during an action the time of its event.
+func (s *Scheduler) Now() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.now
+}
+
+// Schedule adds an event at event.Time, which must not be before Now.
+// thread is the id given to the thread hook scheduling it, or Core.  Events
+// scheduled from a thread hook are queued at the substep's barrier.
+func (s *Scheduler) Schedule(thread int, event Event) (ID, error) {
+	if event.Action == nil {
+		return ID{}, fmt.Errorf("events: the event '%s' has no action", event.Name)
+	}
+	if math.IsNaN(event.Time) {
+		return ID{}, fmt.Errorf("events: the event '%s' has no time", event.Name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if event.Time < s.now {
+		return ID{}, fmt.Errorf("events: cannot schedule '%s' at %v, before the current time %v", event.Name, event.Time, s.now)
+	}
+	// the last count is the core's
+	source := len(s.counts) - 1
+	if thread != Core {
+		if thread < 0 || thread >= len(s.staged) {
+			return ID{}, fmt.Errorf("events: no thread %d to schedule '%s' from", thread, event.Name)
+		}
+		source = thread
+	}
+	s.counts[source] += 1
+	e := &event
+	e.id = ID{thread: thread, n: s.counts[source]}
+	e.index = -1
+	s.events[e.id] = e
+	if thread == Core {
+		s.push(e)
+	} else {
+		s.staged[thread] = append(s.staged[thread], e)
+	}
+	return e.id, nil
+}
+
+// After schedules an event delay after Now, ignoring event.Time.
+func (s *Scheduler) After(thread int, delay float64, event Event) (ID, error) {
+	if delay < 0 {
+		return ID{}, fmt.Errorf("events: cannot schedule '%s' %v before the current time", event.Name, -delay)
+	}
+	event.Time = s.Now() + delay
+	return s.Schedule(thread, event)
+}
+
+// Cancel removes a scheduled event so it does not run.  It returns false
+// if the event already ran or was cancelled.
+func (s *Scheduler) Cancel(id ID) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.events[id]
+	if !ok {
+		return false
+	}
+	delete(s.events, id)
+	if e.index >= 0 {
+		heap.Remove(&s.queue, e.index)
+	}
+	return true
+}
+
+// Len returns the number of scheduled events.
+func (s *Scheduler) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.events)
+}
+
+// Next returns the time of the next event, false if there is none queued.
+func (s *Scheduler) Next() (float64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.merge()
+	if len(s.queue) == 0 {
+		return 0, false
+	}
+	return s.queue[0].Time, true
+}
+
+// RunUntil runs the actions of the events due before end, in order, and
+// then advances the time to end.  The engine calls it at each substep
+// barrier.  It stops at the first action that fails, returning its error,
+// with the time left at that event's.  It returns the number of events
+// that ran.
+func (s *Scheduler) RunUntil(ctx context.Context, end float64) (int, error) {
+	ran := 0
+	for {
+		s.mu.Lock()
+		s.merge()
+		if len(s.queue) == 0 || s.queue[0].Time >= end {
+			if end > s.now {
+				s.now = end
+			}
+			s.mu.Unlock()
+			return ran, nil
+		}
+		e := heap.Pop(&s.queue).(*Event)
+		delete(s.events, e.id)
+		s.now = e.Time
+		s.mu.Unlock()
+
+		// the action may schedule and cancel events
+		if err := e.Action(ctx, e); err != nil {
+			return ran, fmt.Errorf("events: '%s' at %v: %w", e.Name, e.Time, err)
+		}
+		ran += 1
+	}
+}
+
+// merge queues the events staged by the threads in thread order; the lock
+// must be held
+func (s *Scheduler) merge() {
+	for thread, staged := range s.staged {
+		for _, e := range staged {
+			// skip the ones cancelled before the barrier
+			if _, ok := s.events[e.id]; ok {
+				s.push(e)
+			}
+		}
+		s.staged[thread] = nil
+	}
+}
+
+// push queues an event; the lock must be held
+func (s *Scheduler) push(e *Event) {
+	s.sequence += 1
+	e.sequence = s.sequence
+	heap.Push(&s.queue, e)
+}
+
+// queue is a heap of events ordered by time, priority and sequence
+type queue []*Event
+
+func (q queue) Len() int { return len(q) }
+
+func (q queue) Less(i, j int) bool {
+	if q[i].Time != q[j].Time {
+		return q[i].Time < q[j].Time
+	}
+	if q[i].Priority != q[j].Priority {
+		return q[i].Priority < q[j].Priority
+	}
+	return q[i].sequence < q[j].sequence
+}
+
+func (q queue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *queue) Push(x any) {
+	e := x.(*Event)
+	e.index = len(*q)
+	*q = append(*q, e)
+}
+
+func (q *queue) Pop() any {
+	old := *q
+	e := old[len(old)-1]
+	old[len(old)-1] = nil
+	e.index = -1
+	*q = old[:len(old)-1]
+	return e
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// record returns an action that appends the event's name to ran
+func record(ran *[]string) Action {
+	return func(ctx context.Context, e *Event) error {
+		*ran = append(*ran, e.Name)
+		return nil
+	}
+}
+
+func TestOrderAndTieBreaking(t *testing.T) {
+	var ran []string
+	s := NewScheduler(1, 0)
+	for _, e := range []Event{
+		{Time: 2, Name: "late"},
+		{Time: 1, Priority: 1, Name: "low"},
+		{Time: 1, Name: "first"},
+		{Time: 1, Name: "second"},
+		{Time: 0.5, Name: "early"},
+	} {
+		e.Action = record(&ran)
+		if _, err := s.Schedule(Core, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n, err := s.RunUntil(context.Background(), 2); err != nil || n != 4 {
+		t.Fatalf("ran %d events, %v; want 4", n, err)
+	}
+	if fmt.Sprint(ran) != "[early first second low]" {
+		t.Errorf("ran %v", ran)
+	}
+	if s.Now() != 2 || s.Len() != 1 {
+		t.Errorf("at time %v with %d events left, want 2 with 1", s.Now(), s.Len())
+	}
+}
+
+func TestCancel(t *testing.T) {
+	var ran []string
+	s := NewScheduler(2, 0)
+	keep, _ := s.Schedule(Core, Event{Time: 1, Name: "keep", Action: record(&ran)})
+	drop, _ := s.Schedule(Core, Event{Time: 1, Name: "drop", Action: record(&ran)})
+	staged, _ := s.Schedule(1, Event{Time: 1, Name: "staged", Action: record(&ran)})
+	if !s.Cancel(drop) || !s.Cancel(staged) || s.Cancel(drop) {
+		t.Error("cancelling returned the wrong result")
+	}
+	s.RunUntil(context.Background(), 5)
+	if fmt.Sprint(ran) != "[keep]" {
+		t.Errorf("ran %v", ran)
+	}
+	if s.Cancel(keep) {
+		t.Error("cancelled an event that ran")
+	}
+}
+
+func TestScheduleFromUnknownThread(t *testing.T) {
+	s := NewScheduler(2, 0)
+	for _, thread := range []int{2, 3, -2} {
+		if _, err := s.Schedule(thread, Event{Time: 1, Name: "stray", Action: record(nil)}); err == nil {
+			t.Errorf("scheduled an event from thread %d of 2", thread)
+		}
+	}
+	if s.Len() != 0 {
+		t.Errorf("%d events were scheduled", s.Len())
+	}
+}
+
+func TestActionsScheduleEvents(t *testing.T) {
+	var times []float64
+	s := NewScheduler(1, 0)
+	var tick Action
+	tick = func(ctx context.Context, e *Event) error {
+		times = append(times, s.Now())
+		_, err := s.After(Core, 0.75, Event{Name: "tick", Action: tick})
+		return err
+	}
+	s.Schedule(Core, Event{Time: 0, Name: "tick", Action: tick})
+	s.RunUntil(context.Background(), 1)
+	s.RunUntil(context.Background(), 2)
+	if fmt.Sprint(times) != "[0 0.75 1.5]" {
+		t.Errorf("ticked at %v", times)
+	}
+	if _, err := s.Schedule(Core, Event{Time: 1, Name: "past", Action: tick}); err == nil {
+		t.Error("scheduled an event in the past")
+	}
+}
+
+func TestThreadsAreMergedInOrder(t *testing.T) {
+	const threads = 4
+	var ran []string
+	s := NewScheduler(threads, 0)
+	wg := new(sync.WaitGroup)
+	for id := threads - 1; id >= 0; id-- {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 2; i++ {
+				s.Schedule(id, Event{Time: 0.5, Name: fmt.Sprintf("%d.%d", id, i), Action: record(&ran)})
+			}
+		}(id)
+	}
+	wg.Wait()
+	s.RunUntil(context.Background(), 1)
+	if fmt.Sprint(ran) != "[0.0 0.1 1.0 1.1 2.0 2.1 3.0 3.1]" {
+		t.Errorf("ran %v", ran)
+	}
+}
@@ -3,8 +3,11 @@ package example
 import (
 	"context"
 	"errors"
+	"fmt"
 	"log/slog"
 
+	"github.com/dacb/goabe/events"
+	"github.com/dacb/goabe/exchange"
 	"github.com/dacb/goabe/plugins"
 )
 
@@ -13,7 +16,15 @@ var log *slog.Logger
 var threads int
 
 func Register() {
-	plugins.LoadedPlugins = append(plugins.LoadedPlugins, plugins.Plugin{Init, Name, Version, Description, GetHooks, PreRun, PostRun})
+	plugins.Register(plugins.Plugin{
+		Init:        Init,
+		Name:        Name,
+		Version:     Version,
+		Description: Description,
+		GetHooks:    GetHooks,
+		PreRun:      PreRun,
+		PostRun:     PostRun,
+	})
 }
 
 // main initiailization function for the plugin
@@ -51,9 +62,25 @@ func Description() string {
 
 // do before each set of steps
 func PreRun(ctx context.Context) error {
+	// start a recurring event in simulation time
+	if scheduler, ok := ctx.Value("events").(*events.Scheduler); ok {
+		_, err := scheduler.Schedule(events.Core, events.Event{Time: scheduler.Now(), Name: "tick", Action: tick})
+		return err
+	}
 	return nil
 }
 
+// the simulation time between ticks, which need not line up with the steps
+const tickInterval = 2.5
+
+// tick is a scheduled event that schedules the next one
+func tick(ctx context.Context, event *events.Event) error {
+	log.Debug(fmt.Sprintf("tick at time %v", event.Time))
+	scheduler := ctx.Value("events").(*events.Scheduler)
+	_, err := scheduler.After(events.Core, tickInterval, events.Event{Name: "tick", Action: tick})
+	return err
+}
+
 // do after each set of steps
 func PostRun(ctx context.Context) error {
 	return nil
@@ -63,8 +90,8 @@ func GetHooks() []plugins.Hook {
 	log.Debug("example plugin GetHooks function was called")
 
 	var hooks []plugins.Hook
-	hooks = append(hooks, plugins.Hook{0, nil, ThreadSubStep0, "thread sum"})
-	hooks = append(hooks, plugins.Hook{1, CoreSubStep1, nil, "core sum"})
+	hooks = append(hooks, plugins.Hook{SubStep: 0, Thread: ThreadSubStep0, Description: "thread sum"})
+	hooks = append(hooks, plugins.Hook{SubStep: 1, Core: CoreSubStep1, Description: "core sum"})
 
 	return hooks
 }
@@ -73,6 +100,10 @@ func GetHooks() []plugins.Hook {
 func CoreSubStep1(ctx context.Context) error {
 	log := ctx.Value("log").(*slog.Logger).With("plugin", Name())
 	log.Debug("core substep 1 hook called")
+	// read what another plugin shared, if it is running
+	if alive, step, err := exchange.Get[int](ctx, "life.alive_cells"); err == nil {
+		log.Debug(fmt.Sprintf("life had %d alive cells at step %d", alive, step))
+	}
 	return nil
 }
 
@@ -0,0 +1,158 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Core is the thread id to send messages with from core hooks and from
+// Init, PreRun and PostRun.
+const Core = -1
+
+// Message is a message sent on a topic.
+type Message struct {
+	Topic string
+	From  string // the sending plugin instance, for the receiver's information
+	// Delay is the number of substeps to skip before the message is
+	// delivered: 0 delivers it in the substep following the one it is sent
+	// in, substeps-1 in the same substep of the next step.
+	Delay   int
+	Payload any
+
+	// set by the bus
+	Step   int64 // the step the message was sent in
+	SentIn int   // the substep the message was sent in
+	due    int   // the number of barriers left before it is delivered
+}
+
+// Bus carries messages between plugins.  Messages sent during a substep
+// are delivered at a substep barrier and can be received by every hook
+// during the substep they are delivered in, after which they are dropped.
+// The messages of a topic are received in a fixed order: those sent
+// earliest first and, among those sent in the same substep, the ones from
+// thread 0 through the last thread and then the core's, each in the order
+// they were sent.
+type Bus struct {
+	subSteps int
+
+	outboxes []outbox // one per thread, the last for the core
+	pending  []Message
+
+	mu    sync.RWMutex
+	inbox map[string][]Message
+}
+
+// outbox holds the messages sent from a thread during a substep
+type outbox struct {
+	mu       sync.Mutex
+	messages []Message
+}
+
+// NewBus creates a bus for a run with threads threads and subSteps
+// substeps in a step.
+func NewBus(threads, subSteps int) *Bus {
+	return &Bus{
+		subSteps: subSteps,
+		outboxes: make([]outbox, threads+1),
+		inbox:    map[string][]Message{},
+	}
+}
+
+// Send queues a message for delivery.  thread is the id given to the
+// thread hook sending it, or Core.
+func (b *Bus) Send(thread int, message Message) error {
+	if message.Delay < 0 {
+		return fmt.Errorf("exchange: cannot delay a message on '%s' by %d substeps", message.Topic, message.Delay)
+	}
+	if thread == Core {
+		thread = len(b.outboxes) - 1
+	}
+	if thread < 0 || thread >= len(b.outboxes) {
+		return fmt.Errorf("exchange: no thread %d to send a message on '%s' from", thread, message.Topic)
+	}
+	out := &b.outboxes[thread]
+	out.mu.Lock()
+	defer out.mu.Unlock()
+	out.messages = append(out.messages, message)
+	return nil
+}
+
+// Receive returns the messages on a topic delivered for the current
+// substep.  The slice must not be modified.
+func (b *Bus) Receive(topic string) []Message {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.inbox[topic]
+}
+
+// Deliver ends the substep that was running: it collects the messages sent
+// during it and delivers those due in the next substep.  The engine calls
+// it at each substep barrier with the step and substep that just finished.
+func (b *Bus) Deliver(step int64, subStep int) {
+	for i := range b.outboxes {
+		out := &b.outboxes[i]
+		out.mu.Lock()
+		for _, message := range out.messages {
+			message.Step, message.SentIn = step, subStep
+			message.due = message.Delay + 1
+			b.pending = append(b.pending, message)
+		}
+		out.messages = nil
+		out.mu.Unlock()
+	}
+
+	inbox := map[string][]Message{}
+	kept := b.pending[:0]
+	for _, message := range b.pending {
+		message.due -= 1
+		if message.due == 0 {
+			inbox[message.Topic] = append(inbox[message.Topic], message)
+		} else {
+			kept = append(kept, message)
+		}
+	}
+	b.pending = kept
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.inbox = inbox
+}
+
+// Begin delivers the messages sent before the first step, e.g., from Init
+// or PreRun, as if they were sent in the last substep of step -1; the
+// engine calls it before the first step.
+func (b *Bus) Begin() {
+	b.Deliver(-1, b.subSteps-1)
+}
+
+// Send queues a message on the bus on the context; see Bus.Send.
+func Send(ctx context.Context, thread int, message Message) error {
+	bus, ok := ctx.Value("bus").(*Bus)
+	if !ok {
+		return fmt.Errorf("exchange: no message bus on the context to send on '%s'", message.Topic)
+	}
+	return bus.Send(thread, message)
+}
+
+// Receive returns the messages on a topic delivered for the current
+// substep on the bus on the context, none if there is no bus.
+func Receive(ctx context.Context, topic string) []Message {
+	bus, ok := ctx.Value("bus").(*Bus)
+	if !ok {
+		return nil
+	}
+	return bus.Receive(topic)
+}
+
+// Payloads returns the payloads of the messages on a topic delivered for
+// the current substep that are T's, in the order they are received.
+func Payloads[T any](ctx context.Context, topic string) []T {
+	var payloads []T
+	for _, message := range Receive(ctx, topic) {
+		if payload, ok := message.Payload.(T); ok {
+			payloads = append(payloads, payload)
+		}
+	}
+	return payloads
+}
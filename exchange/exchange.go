@@ -0,0 +1,147 @@
+// Package exchange lets plugins share data with each other during a run.
+// The engine puts a Registry of named values on the context as "data" and a
+// Bus of messages as "bus".  Both are safe to use from any hook, including
+// thread hooks, and both are synchronized with the engine's substeps: what
+// is published or sent during a substep is seen by the other hooks only
+// after the substep's barrier, once every thread and core hook of the
+// substep has run.  A run therefore sees the same values and messages in
+// the same order whatever the number of threads and however the threads
+// are scheduled.
+//
+// For example, life publishes its count of alive cells in its core hook
+//
+//	exchange.Publish(ctx, "life.alive_cells", aliveCells)
+//
+// and any plugin reads it in a later substep
+//
+//	alive, step, err := exchange.Get[int](ctx, "life.alive_cells")
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// Registry holds named values published by plugins.  Values published
+// during a substep are staged and become visible at the substep's barrier;
+// they stay visible until they are published again.
+type Registry struct {
+	mu     sync.RWMutex
+	values map[string]entry
+	staged map[string]any
+	types  map[string]reflect.Type
+}
+
+// entry is a published value and the step it was published in
+type entry struct {
+	value any
+	step  int64
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		values: map[string]entry{},
+		staged: map[string]any{},
+		types:  map[string]reflect.Type{},
+	}
+}
+
+// Set publishes a value under a name, by convention prefixed with the
+// plugin's configuration section, e.g., life.alive_cells.  The first value
+// published under a name fixes its type; publishing a value of another
+// type is an error.  If a name is published more than once in a substep
+// the last value wins, so a name should have a single publisher.
+func (r *Registry) Set(name string, value any) error {
+	if value == nil {
+		return fmt.Errorf("exchange: cannot publish nil as '%s'", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	valueType := reflect.TypeOf(value)
+	if known, ok := r.types[name]; ok && known != valueType {
+		return fmt.Errorf("exchange: '%s' is a %v, cannot publish a %v", name, known, valueType)
+	}
+	r.types[name] = valueType
+	r.staged[name] = value
+	return nil
+}
+
+// Lookup returns the visible value of a name and the step it was published
+// in, -1 if it was published before the first step.
+func (r *Registry) Lookup(name string) (any, int64, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	e, ok := r.values[name]
+	return e.value, e.step, ok
+}
+
+// Names returns the names with a visible value, in no particular order.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.values))
+	for name := range r.values {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Commit makes the values staged during a substep of step visible; the
+// engine calls it at each substep barrier.
+func (r *Registry) Commit(step int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, value := range r.staged {
+		r.values[name] = entry{value: value, step: step}
+	}
+	r.staged = map[string]any{}
+}
+
+// Table is a value for publishing rows of numbers with named columns, e.g.,
+// the state of each cell of a grid.
+type Table struct {
+	Columns []string
+	Rows    [][]float64
+}
+
+// Column returns the index of the named column, -1 if there is none.
+func (t Table) Column(name string) int {
+	for i, column := range t.Columns {
+		if column == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Publish sets a value in the registry on the context.
+func Publish(ctx context.Context, name string, value any) error {
+	registry, ok := ctx.Value("data").(*Registry)
+	if !ok {
+		return fmt.Errorf("exchange: no data registry on the context to publish '%s'", name)
+	}
+	return registry.Set(name, value)
+}
+
+// Get returns the visible value of a name in the registry on the context
+// as a T, and the step it was published in.  It is an error if nothing was
+// published under the name yet or if the value is not a T.
+func Get[T any](ctx context.Context, name string) (T, int64, error) {
+	var zero T
+	registry, ok := ctx.Value("data").(*Registry)
+	if !ok {
+		return zero, 0, fmt.Errorf("exchange: no data registry on the context to get '%s'", name)
+	}
+	value, step, ok := registry.Lookup(name)
+	if !ok {
+		return zero, 0, fmt.Errorf("exchange: nothing was published as '%s'", name)
+	}
+	typed, ok := value.(T)
+	if !ok {
+		return zero, 0, fmt.Errorf("exchange: '%s' is a %T, not a %T", name, value, zero)
+	}
+	return typed, step, nil
+}
@@ -0,0 +1,99 @@
+package exchange
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+func TestRegistryStagesUntilCommit(t *testing.T) {
+	r := NewRegistry()
+	ctx := context.WithValue(context.Background(), "data", r)
+	if err := Publish(ctx, "a.count", 3); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Get[int](ctx, "a.count"); err == nil {
+		t.Error("a value was visible before the barrier")
+	}
+	r.Commit(7)
+	count, step, err := Get[int](ctx, "a.count")
+	if err != nil || count != 3 || step != 7 {
+		t.Errorf("got %d at step %d, %v; want 3 at step 7", count, step, err)
+	}
+
+	if err := Publish(ctx, "a.count", 4.5); err == nil {
+		t.Error("published a float64 as an int")
+	}
+	if _, _, err := Get[string](ctx, "a.count"); err == nil {
+		t.Error("got an int as a string")
+	}
+	if _, _, err := Get[int](context.Background(), "a.count"); err == nil {
+		t.Error("got a value without a registry")
+	}
+}
+
+func TestBusDeliversInThreadOrder(t *testing.T) {
+	const threads = 4
+	b := NewBus(threads, 2)
+	ctx := context.WithValue(context.Background(), "bus", b)
+
+	wg := new(sync.WaitGroup)
+	for id := threads - 1; id >= 0; id-- {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			for i := 0; i < 3; i++ {
+				Send(ctx, id, Message{Topic: "t", Payload: fmt.Sprintf("%d.%d", id, i)})
+			}
+		}(id)
+	}
+	wg.Wait()
+	Send(ctx, Core, Message{Topic: "t", Payload: "core"})
+	if len(Receive(ctx, "t")) != 0 {
+		t.Error("a message was delivered before the barrier")
+	}
+
+	b.Deliver(0, 0)
+	got := fmt.Sprint(Payloads[string](ctx, "t"))
+	want := "[0.0 0.1 0.2 1.0 1.1 1.2 2.0 2.1 2.2 3.0 3.1 3.2 core]"
+	if got != want {
+		t.Errorf("received %s, want %s", got, want)
+	}
+
+	// messages are dropped after the substep they are delivered in
+	b.Deliver(0, 1)
+	if len(Receive(ctx, "t")) != 0 {
+		t.Error("a message was delivered twice")
+	}
+}
+
+func TestBusDeliversInSubStep(t *testing.T) {
+	b := NewBus(1, 3)
+	// sent in substep 1 for substep 0, so delivered in the next step
+	b.Deliver(0, 0)
+	if err := b.Send(0, Message{Topic: "t", Delay: 1, Payload: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Send(0, Message{Topic: "t", Delay: -1}); err == nil {
+		t.Error("sent a message with a negative delay")
+	}
+	b.Deliver(0, 1)
+	if len(b.Receive("t")) != 0 {
+		t.Error("delivered a message in substep 2, want substep 0")
+	}
+	b.Deliver(0, 2)
+	messages := b.Receive("t")
+	if len(messages) != 1 || messages[0].Step != 0 || messages[0].SentIn != 1 {
+		t.Errorf("received %+v in substep 0 of step 1", messages)
+	}
+}
+
+func TestBusBeginDeliversMessagesSentBeforeTheRun(t *testing.T) {
+	b := NewBus(2, 2)
+	b.Send(Core, Message{Topic: "setup", Payload: "hello"})
+	b.Begin()
+	if len(b.Receive("setup")) != 1 {
+		t.Error("a message sent before the run was not delivered in substep 0")
+	}
+}
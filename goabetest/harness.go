@@ -0,0 +1,341 @@
+// Package goabetest runs plugins in tests without the goabe binary.  A
+// Harness builds the context the engine gives plugins, with a logger, the
+// thread count, a seed and the configuration, initializes a plugin and then
+// drives its hooks step by step the way the engine does: in each substep
+// the thread hooks run at the same time on every thread, then the core
+// hooks run alone.  Tests inspect the plugin's state between steps.
+//
+//	func TestMain(m *testing.M) {
+//		Register()
+//		os.Exit(m.Run())
+//	}
+//
+//	func TestBlinker(t *testing.T) {
+//		h := goabetest.New(t, "life", goabetest.Threads(4), goabetest.Set("life.x_size", 5))
+//		h.Run(2)
+//		...
+//		h.Finish()
+//	}
+package goabetest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dacb/goabe/events"
+	"github.com/dacb/goabe/exchange"
+	"github.com/dacb/goabe/plugins"
+
+	"github.com/spf13/viper"
+)
+
+// Harness drives one plugin.  Its methods must be called from the test's
+// goroutine.
+type Harness struct {
+	t        testing.TB
+	plugin   plugins.Plugin
+	threads  int
+	subSteps int
+	seed     int64
+	values   map[any]any
+	log      *slog.Logger
+	data     *exchange.Registry
+	bus      *exchange.Bus
+	events   *events.Scheduler
+	duration float64
+	ctx      context.Context
+	step     int64
+	finished bool
+
+	mu      sync.Mutex
+	metrics map[string]map[int64]float64
+	panels  map[string][]string
+}
+
+// Option configures a Harness.
+type Option func(*Harness)
+
+// Threads sets the number of threads, 1 by default.
+func Threads(n int) Option {
+	return func(h *Harness) { h.threads = n }
+}
+
+// SubSteps sets the number of substeps in a step, by default the substeps
+// configuration value.
+func SubSteps(n int) Option {
+	return func(h *Harness) { h.subSteps = n }
+}
+
+// StepDuration sets the simulation time that passes in a step, by default
+// the step_duration configuration value.
+func StepDuration(duration float64) Option {
+	return func(h *Harness) { h.duration = duration }
+}
+
+// Seed sets the random seed given to the plugin, 1 by default.
+func Seed(seed int64) Option {
+	return func(h *Harness) { h.seed = seed }
+}
+
+// Set overrides a configuration value for the test, e.g.,
+// Set("life.x_size", 8).  The previous value is restored when the test ends.
+func Set(key string, value any) Option {
+	return func(h *Harness) {
+		previous := viper.Get(key)
+		viper.Set(key, value)
+		h.t.Cleanup(func() { viper.Set(key, previous) })
+	}
+}
+
+// Value puts an extra value on the plugin's context.
+func Value(key, value any) Option {
+	return func(h *Harness) { h.values[key] = value }
+}
+
+// New finds the registered plugin with the name, which is not case
+// sensitive, and starts it as Start does.  The plugin's Register function
+// must have been called, normally from TestMain.
+func New(t testing.TB, name string, options ...Option) *Harness {
+	t.Helper()
+	plugin, ok := plugins.FindPlugin(name)
+	if !ok {
+		t.Fatalf("no plugin named '%s' is registered, call its Register function first", name)
+	}
+	return Start(t, plugin, options...)
+}
+
+// Start calls the Init and PreRun of a plugin, e.g., a new instance made
+// for the test, whose configuration was registered.  The logs go to the
+// test log.  The plugin is shut down when the test ends if Finish was not
+// called.
+func Start(t testing.TB, plugin plugins.Plugin, options ...Option) *Harness {
+	t.Helper()
+	h := &Harness{
+		t:        t,
+		plugin:   plugin,
+		threads:  1,
+		subSteps: viper.GetInt("substeps"),
+		duration: viper.GetFloat64("step_duration"),
+		seed:     1,
+		values:   map[any]any{},
+		metrics:  map[string]map[int64]float64{},
+		panels:   map[string][]string{},
+	}
+	for _, option := range options {
+		option(h)
+	}
+	if h.subSteps < 1 {
+		// the engine's default
+		h.subSteps = 10
+	}
+	if h.duration <= 0 {
+		h.duration = 1
+	}
+	h.log = slog.New(slog.NewTextHandler(testWriter{t}, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	h.data = exchange.NewRegistry()
+	h.bus = exchange.NewBus(h.threads, h.subSteps)
+	h.events = events.NewScheduler(h.threads, 0)
+
+	ctx := context.WithValue(context.Background(), "log", h.log)
+	ctx = context.WithValue(ctx, "threads", h.threads)
+	ctx = context.WithValue(ctx, "random_seed", h.seed)
+	ctx = context.WithValue(ctx, "metrics", plugins.Metrics(metricsRecorder{h}))
+	ctx = context.WithValue(ctx, "display", plugins.Display(displayRecorder{h}))
+	ctx = context.WithValue(ctx, "data", h.data)
+	ctx = context.WithValue(ctx, "bus", h.bus)
+	ctx = context.WithValue(ctx, "events", h.events)
+	for key, value := range h.values {
+		ctx = context.WithValue(ctx, key, value)
+	}
+	h.ctx = ctx
+
+	if err := plugin.Init(ctx); err != nil {
+		t.Fatalf("%s Init: %v", plugin.Name(), err)
+	}
+	t.Cleanup(h.shutdown)
+	// as in the engine, a hook that would never run is an error
+	for _, hook := range plugin.GetHooks() {
+		if hook.SubStep < 0 || hook.SubStep >= h.subSteps {
+			t.Fatalf("%s has the hook '%s' at substep %d, but the substeps are 0 to %d", plugin.Name(), hook.Description, hook.SubStep, h.subSteps-1)
+		}
+	}
+	if err := plugin.PreRun(ctx); err != nil {
+		t.Fatalf("%s PreRun: %v", plugin.Name(), err)
+	}
+	h.data.Commit(-1)
+	h.bus.Begin()
+	return h
+}
+
+// Context returns the context given to the plugin's Init, PreRun and
+// PostRun, nil once it is shut down.
+func (h *Harness) Context() context.Context {
+	return h.ctx
+}
+
+// Steps returns the number of steps run so far.
+func (h *Harness) Steps() int64 {
+	return h.step
+}
+
+// Step runs one step: each substep's thread hooks on every thread at once,
+// waiting for all of them, then its core hooks.
+func (h *Harness) Step() {
+	h.t.Helper()
+	if h.finished {
+		h.t.Fatal("step after Finish")
+	}
+	hooks := map[int][]plugins.Hook{}
+	for _, hook := range h.plugin.GetHooks() {
+		hooks[hook.SubStep] = append(hooks[hook.SubStep], hook)
+	}
+	coreCtx := context.WithValue(h.ctx, "log", h.log.With("actor", "core").With("step", h.step))
+	for subStep := 0; subStep < h.subSteps; subStep++ {
+		errs := make([]error, h.threads)
+		wg := new(sync.WaitGroup)
+		for id := 0; id < h.threads; id++ {
+			wg.Add(1)
+			go func(id int) {
+				defer wg.Done()
+				name := fmt.Sprintf("thread_%d", id)
+				ctx := context.WithValue(h.ctx, "log", h.log.With("actor", name).With("step", h.step))
+				for _, hook := range hooks[subStep] {
+					if hook.Thread == nil {
+						continue
+					}
+					if err := hook.Thread(ctx, id, name); err != nil {
+						errs[id] = fmt.Errorf("thread hook '%s' at step %d substep %d on %s: %w", hook.Description, h.step, subStep, name, err)
+						return
+					}
+				}
+			}(id)
+		}
+		wg.Wait()
+		for _, err := range errs {
+			if err != nil {
+				h.t.Fatal(err)
+			}
+		}
+		for _, hook := range hooks[subStep] {
+			if hook.Core == nil {
+				continue
+			}
+			if err := hook.Core(coreCtx); err != nil {
+				h.t.Fatalf("core hook '%s' at step %d substep %d: %v", hook.Description, h.step, subStep, err)
+			}
+		}
+		end := (float64(h.step) + float64(subStep+1)/float64(h.subSteps)) * h.duration
+		if _, err := h.events.RunUntil(coreCtx, end); err != nil {
+			h.t.Fatalf("at step %d substep %d: %v", h.step, subStep, err)
+		}
+		h.data.Commit(h.step)
+		h.bus.Deliver(h.step, subStep)
+	}
+	h.step += 1
+}
+
+// Run runs n steps.
+func (h *Harness) Run(n int) {
+	h.t.Helper()
+	for i := 0; i < n; i++ {
+		h.Step()
+	}
+}
+
+// Finish calls the plugin's PostRun and Shutdown.
+func (h *Harness) Finish() {
+	h.t.Helper()
+	h.finished = true
+	if err := h.plugin.PostRun(h.ctx); err != nil {
+		h.t.Fatalf("%s PostRun: %v", h.plugin.Name(), err)
+	}
+	h.shutdown()
+}
+
+func (h *Harness) shutdown() {
+	h.t.Helper()
+	h.finished = true
+	if h.plugin.Shutdown == nil || h.ctx == nil {
+		return
+	}
+	ctx := h.ctx
+	h.ctx = nil
+	if err := h.plugin.Shutdown(ctx); err != nil {
+		h.t.Errorf("%s Shutdown: %v", h.plugin.Name(), err)
+	}
+}
+
+// Data returns the registry the plugin publishes to and reads from.
+func (h *Harness) Data() *exchange.Registry {
+	return h.data
+}
+
+// Publish sets a value in the registry that is visible to the plugin from
+// the next step on, e.g., the output of a plugin it is coupled to.
+func (h *Harness) Publish(name string, value any) {
+	h.t.Helper()
+	if err := h.data.Set(name, value); err != nil {
+		h.t.Fatal(err)
+	}
+	h.data.Commit(h.step - 1)
+}
+
+// Bus returns the message bus the plugin sends on and receives from.
+// Messages sent on it between steps are delivered at the end of the first
+// substep of the next step.
+func (h *Harness) Bus() *exchange.Bus {
+	return h.bus
+}
+
+// Events returns the scheduler of the plugin's events.
+func (h *Harness) Events() *events.Scheduler {
+	return h.events
+}
+
+// Metric returns the value of a metric the plugin published in step.
+func (h *Harness) Metric(name string, step int64) (float64, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	value, ok := h.metrics[name][step]
+	return value, ok
+}
+
+// Panel returns the lines the plugin last drew into a panel.
+func (h *Harness) Panel(name string) []string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.panels[name]
+}
+
+// metricsRecorder records the metrics of the harness' current step
+type metricsRecorder struct{ h *Harness }
+
+func (r metricsRecorder) Set(name string, value float64) {
+	r.h.mu.Lock()
+	defer r.h.mu.Unlock()
+	if r.h.metrics[name] == nil {
+		r.h.metrics[name] = map[int64]float64{}
+	}
+	r.h.metrics[name][r.h.step] = value
+}
+
+// displayRecorder keeps what plugins draw rather than writing to the terminal
+type displayRecorder struct{ h *Harness }
+
+func (r displayRecorder) Draw(panel string, lines []string) {
+	r.h.mu.Lock()
+	defer r.h.mu.Unlock()
+	r.h.panels[panel] = append([]string(nil), lines...)
+}
+
+// testWriter sends log lines to the test log
+type testWriter struct{ t testing.TB }
+
+func (w testWriter) Write(p []byte) (int, error) {
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
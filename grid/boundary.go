@@ -0,0 +1,102 @@
+package grid
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Boundary is what lies beyond the edges of a grid.
+type Boundary int
+
+const (
+	// Torus wraps both edges around: the neighbor past the right edge is
+	// on the left edge and the one past the bottom is on the top.
+	Torus Boundary = iota
+	// Fixed has no cells past the edges, as if the grid were bordered by
+	// cells that never change; cells near the edges have fewer neighbors.
+	Fixed
+	// Reflecting mirrors the grid at its edges, so the neighbor past an
+	// edge is the cell the same distance inside it from the edge cell,
+	// e.g., the neighbor left of column 0 is column 1; an edge cell is
+	// never its own neighbor.
+	Reflecting
+	// Klein wraps the left and right edges around like Torus and the top
+	// and bottom edges around with a twist: the neighbor past the bottom
+	// of column x is on the top of column width-1-x, making a Klein
+	// bottle.
+	Klein
+)
+
+var boundaryNames = []string{"torus", "fixed", "reflecting", "klein"}
+
+// String returns the boundary's name, e.g., torus.
+func (b Boundary) String() string {
+	if b < Torus || b > Klein {
+		return fmt.Sprintf("Boundary(%d)", int(b))
+	}
+	return boundaryNames[b]
+}
+
+// ParseBoundary returns the boundary with the name, e.g., from a
+// configuration value; case does not matter.
+func ParseBoundary(name string) (Boundary, error) {
+	for b, known := range boundaryNames {
+		if strings.EqualFold(name, known) {
+			return Boundary(b), nil
+		}
+	}
+	return Torus, fmt.Errorf("grid: unknown boundary '%s', expecting one of %s", name, strings.Join(boundaryNames, ", "))
+}
+
+// fold maps a position that may be off the grid onto it according to the
+// boundary, false if it has no cell there
+func (g *Grid[T]) fold(x, y int) (int, int, bool) {
+	switch g.boundary {
+	case Fixed:
+		if x < 0 || x >= g.width || y < 0 || y >= g.height {
+			return 0, 0, false
+		}
+	case Reflecting:
+		x, y = reflect(x, g.width), reflect(y, g.height)
+	case Klein:
+		// each time y crosses the top or bottom x is mirrored
+		if floorDiv(y, g.height)%2 != 0 {
+			x = -1 - x
+		}
+		x, y = mod(x, g.width), mod(y, g.height)
+	default:
+		x, y = mod(x, g.width), mod(y, g.height)
+	}
+	return x, y, true
+}
+
+// reflect folds n into 0 to size-1 by mirroring it about the edge cells,
+// as many times as it takes, so -1 is 1 and size is size-2
+func reflect(n, size int) int {
+	if size == 1 {
+		return 0
+	}
+	period := 2 * (size - 1)
+	n = mod(n, period)
+	if n >= size {
+		n = period - n
+	}
+	return n
+}
+
+// mod returns n modulo size in 0 to size-1, also for negative n
+func mod(n, size int) int {
+	n %= size
+	if n < 0 {
+		n += size
+	}
+	return n
+}
+
+// floorDiv returns n divided by size rounded down
+func floorDiv(n, size int) int {
+	if n < 0 {
+		return -((-n + size - 1) / size)
+	}
+	return n / size
+}
@@ -0,0 +1,168 @@
+// Package grid is a two dimensional space of cells for plugins, such as
+// life's matrix.  A Grid holds a value of any type in each cell, in two
+// buffers so that a synchronous update can read the current state of every
+// cell while writing the next one, and it works out the neighbors of every
+// cell once, for a Neighborhood of any radius and a Boundary, so that the
+// hooks iterate over them without any edge cases:
+//
+//	g, err := grid.New[bool](64, 64, grid.Torus, grid.Moore(1))
+//	...
+//	// in a thread hook
+//	for _, i := range g.Part(grid.Rows, id, threads) {
+//		alive := 0
+//		for _, n := range g.Neighbors(i) {
+//			if g.At(n) {
+//				alive += 1
+//			}
+//		}
+//		g.SetNext(i, alive == 3 || g.At(i) && alive == 2)
+//	}
+//	// in the core hook
+//	g.Swap()
+//
+// Cells are numbered row by row: the cell at x, y is Index(x, y), which is
+// y*width + x.
+package grid
+
+import "fmt"
+
+// Grid is a width by height space of cells of type T.
+type Grid[T any] struct {
+	width, height int
+	boundary      Boundary
+	neighborhood  Neighborhood
+
+	cells, next []T
+	// the neighbors of cell i are neighbors[start[i]:start[i+1]]
+	neighbors []int
+	start     []int
+
+	parts parts
+}
+
+// New creates a grid whose cells all have T's zero value.
+func New[T any](width, height int, boundary Boundary, neighborhood Neighborhood) (*Grid[T], error) {
+	if width < 1 || height < 1 {
+		return nil, fmt.Errorf("grid: invalid size %d by %d", width, height)
+	}
+	if boundary < Torus || boundary > Klein {
+		return nil, fmt.Errorf("grid: invalid boundary %d", boundary)
+	}
+	g := &Grid[T]{
+		width:        width,
+		height:       height,
+		boundary:     boundary,
+		neighborhood: neighborhood,
+		cells:        make([]T, width*height),
+		next:         make([]T, width*height),
+		start:        make([]int, width*height+1),
+	}
+	// work out the neighbors once, so iterating over them needs no
+	// boundary checks
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for _, offset := range neighborhood {
+				if nx, ny, ok := g.fold(x+offset.X, y+offset.Y); ok {
+					g.neighbors = append(g.neighbors, g.Index(nx, ny))
+				}
+			}
+			g.start[g.Index(x, y)+1] = len(g.neighbors)
+		}
+	}
+	return g, nil
+}
+
+// Width returns the number of columns.
+func (g *Grid[T]) Width() int {
+	return g.width
+}
+
+// Height returns the number of rows.
+func (g *Grid[T]) Height() int {
+	return g.height
+}
+
+// Len returns the number of cells.
+func (g *Grid[T]) Len() int {
+	return len(g.cells)
+}
+
+// Boundary returns the grid's boundary.
+func (g *Grid[T]) Boundary() Boundary {
+	return g.boundary
+}
+
+// Neighborhood returns the grid's neighborhood.
+func (g *Grid[T]) Neighborhood() Neighborhood {
+	return g.neighborhood
+}
+
+// Index returns the number of the cell at x, y, which must be on the grid.
+func (g *Grid[T]) Index(x, y int) int {
+	return y*g.width + x
+}
+
+// Point returns the coordinates of cell i.
+func (g *Grid[T]) Point(i int) (int, int) {
+	return i % g.width, i / g.width
+}
+
+// Neighbors returns the numbers of the neighbors of cell i, in the order
+// of the neighborhood's offsets.  With the Fixed boundary the cells near
+// the edges have fewer neighbors; on a small grid a cell may be its own
+// neighbor or have the same neighbor twice.  The slice must not be
+// modified.
+func (g *Grid[T]) Neighbors(i int) []int {
+	return g.neighbors[g.start[i]:g.start[i+1]]
+}
+
+// At returns the current value of cell i.
+func (g *Grid[T]) At(i int) T {
+	return g.cells[i]
+}
+
+// Get returns the current value of the cell at x, y.
+func (g *Grid[T]) Get(x, y int) T {
+	return g.cells[g.Index(x, y)]
+}
+
+// Put sets the current value of cell i, e.g., for the starting state.
+func (g *Grid[T]) Put(i int, value T) {
+	g.cells[i] = value
+}
+
+// Set sets the current value of the cell at x, y.
+func (g *Grid[T]) Set(x, y int, value T) {
+	g.cells[g.Index(x, y)] = value
+}
+
+// Cells returns the current values of all of the cells.  The slice must
+// not be modified while other hooks read the grid.
+func (g *Grid[T]) Cells() []T {
+	return g.cells
+}
+
+// SetNext sets the next value of cell i, which becomes its current value
+// at Swap.  Threads may set the next values of different cells at once.
+func (g *Grid[T]) SetNext(i int, value T) {
+	g.next[i] = value
+}
+
+// Next returns the next value of cell i.
+func (g *Grid[T]) Next(i int) T {
+	return g.next[i]
+}
+
+// Swap makes the next values the current ones.  Every cell's next value
+// should have been set since the last Swap, or CopyToNext called first;
+// otherwise the cell gets the value it had before the last Swap.  It must
+// not be called while hooks read the grid, e.g., call it from a core hook.
+func (g *Grid[T]) Swap() {
+	g.cells, g.next = g.next, g.cells
+}
+
+// CopyToNext sets the next value of every cell to its current value, for
+// updates that only change some of the cells.
+func (g *Grid[T]) CopyToNext() {
+	copy(g.next, g.cells)
+}
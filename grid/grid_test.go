@@ -0,0 +1,135 @@
+package grid
+
+import (
+	"fmt"
+	"sort"
+	"testing"
+)
+
+// neighbors returns the coordinates of the neighbors of x, y, sorted
+func neighbors(g *Grid[int], x, y int) string {
+	var points []string
+	for _, n := range g.Neighbors(g.Index(x, y)) {
+		nx, ny := g.Point(n)
+		points = append(points, fmt.Sprintf("%d,%d", nx, ny))
+	}
+	sort.Strings(points)
+	return fmt.Sprint(points)
+}
+
+func TestNeighborhoodSizes(t *testing.T) {
+	for _, test := range []struct {
+		name         string
+		neighborhood Neighborhood
+		want         int
+	}{
+		{"moore 1", Moore(1), 8},
+		{"moore 2", Moore(2), 24},
+		{"von neumann 1", VonNeumann(1), 4},
+		{"von neumann 2", VonNeumann(2), 12},
+		{"hex 1", Hex(1), 6},
+		{"hex 2", Hex(2), 18},
+	} {
+		if len(test.neighborhood) != test.want {
+			t.Errorf("%s has %d cells, want %d", test.name, len(test.neighborhood), test.want)
+		}
+	}
+}
+
+func TestBoundaries(t *testing.T) {
+	for _, test := range []struct {
+		boundary Boundary
+		x, y     int
+		want     string
+	}{
+		{Torus, 0, 0, "[0,1 0,4 1,0 4,0]"},
+		{Fixed, 0, 0, "[0,1 1,0]"},
+		{Fixed, 2, 2, "[1,2 2,1 2,3 3,2]"},
+		// mirrored about the edge cells, which are not their own neighbors
+		{Reflecting, 0, 0, "[0,1 0,1 1,0 1,0]"},
+		{Reflecting, 4, 2, "[3,2 3,2 4,1 4,3]"},
+		// past the top of column 1 is the bottom of column 3
+		{Klein, 1, 0, "[0,0 1,1 2,0 3,4]"},
+		{Klein, 1, 4, "[0,4 1,3 2,4 3,0]"},
+	} {
+		g, err := New[int](5, 5, test.boundary, VonNeumann(1))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := neighbors(g, test.x, test.y); got != test.want {
+			t.Errorf("%v: the neighbors of %d,%d are %s, want %s", test.boundary, test.x, test.y, got, test.want)
+		}
+	}
+}
+
+func TestLargeRadiusFolds(t *testing.T) {
+	for _, boundary := range []Boundary{Torus, Reflecting, Klein} {
+		g, err := New[int](3, 2, boundary, Moore(4))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < g.Len(); i++ {
+			if len(g.Neighbors(i)) != 80 {
+				t.Errorf("%v: cell %d has %d neighbors, want 80", boundary, i, len(g.Neighbors(i)))
+			}
+			for _, n := range g.Neighbors(i) {
+				if n < 0 || n >= g.Len() {
+					t.Fatalf("%v: cell %d has neighbor %d off the grid", boundary, i, n)
+				}
+			}
+		}
+	}
+}
+
+func TestParseBoundary(t *testing.T) {
+	for _, b := range []Boundary{Torus, Fixed, Reflecting, Klein} {
+		if parsed, err := ParseBoundary(b.String()); err != nil || parsed != b {
+			t.Errorf("parsed %s as %v, %v", b, parsed, err)
+		}
+	}
+	if _, err := ParseBoundary("sphere"); err == nil {
+		t.Error("parsed an unknown boundary")
+	}
+}
+
+func TestPartsCoverEveryCellOnce(t *testing.T) {
+	g, _ := New[int](7, 5, Torus, Moore(1))
+	for _, partition := range []Partition{Rows, Tiles} {
+		for _, threads := range []int{1, 2, 4, 6, 7, 9} {
+			seen := make([]int, g.Len())
+			for id := 0; id < threads; id++ {
+				for _, i := range g.Part(partition, id, threads) {
+					seen[i] += 1
+				}
+			}
+			for i, n := range seen {
+				if n != 1 {
+					t.Fatalf("partition %d over %d threads has cell %d %d times", partition, threads, i, n)
+				}
+			}
+		}
+	}
+	// 4 threads tile the grid 2 by 2
+	if got := fmt.Sprint(g.Part(Tiles, 3, 4)); got != "[25 26 27 32 33 34]" {
+		t.Errorf("the last of 4 tiles is %s", got)
+	}
+}
+
+func TestSwap(t *testing.T) {
+	g, _ := New[int](2, 2, Fixed, Moore(1))
+	g.Set(1, 0, 5)
+	for i := 0; i < g.Len(); i++ {
+		sum := 0
+		for _, n := range g.Neighbors(i) {
+			sum += g.At(n)
+		}
+		g.SetNext(i, sum)
+	}
+	if g.Get(1, 0) != 5 {
+		t.Error("setting the next values changed the current ones")
+	}
+	g.Swap()
+	if fmt.Sprint(g.Cells()) != "[5 0 5 5]" {
+		t.Errorf("after the swap the cells are %v, want [5 0 5 5]", g.Cells())
+	}
+}
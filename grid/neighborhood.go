@@ -0,0 +1,54 @@
+package grid
+
+// Offset is the position of a neighbor relative to a cell.
+type Offset struct {
+	X, Y int
+}
+
+// Neighborhood is the offsets of the neighbors of a cell.  Any list of
+// offsets is a neighborhood, e.g., Neighborhood{{0, -1}, {0, 1}} for the
+// cells above and below.
+type Neighborhood []Offset
+
+// Within returns the neighborhood of the offsets up to radius away in x
+// and y that include accepts, other than the cell itself, row by row.
+func Within(radius int, include func(dx, dy int) bool) Neighborhood {
+	var neighborhood Neighborhood
+	for dy := -radius; dy <= radius; dy++ {
+		for dx := -radius; dx <= radius; dx++ {
+			if (dx != 0 || dy != 0) && include(dx, dy) {
+				neighborhood = append(neighborhood, Offset{dx, dy})
+			}
+		}
+	}
+	return neighborhood
+}
+
+// Moore returns the square neighborhood of the cells up to radius away in
+// both x and y, the 8 surrounding cells for radius 1.
+func Moore(radius int) Neighborhood {
+	return Within(radius, func(dx, dy int) bool { return true })
+}
+
+// VonNeumann returns the diamond neighborhood of the cells up to radius
+// steps away along the rows and columns, the 4 adjacent cells for radius
+// 1.
+func VonNeumann(radius int) Neighborhood {
+	return Within(radius, func(dx, dy int) bool { return abs(dx)+abs(dy) <= radius })
+}
+
+// Hex returns the neighborhood of the cells up to radius away on a
+// hexagonal grid, 6 for radius 1.  The grid's cells are hexagons in axial
+// coordinates: each row is shifted half a cell to the right of the one
+// above it, so the grid is a rhombus, and the neighbors of x, y at radius
+// 1 are x±1, y and x, y±1 and x+1, y-1 and x-1, y+1.
+func Hex(radius int) Neighborhood {
+	return Within(radius, func(dx, dy int) bool { return abs(dx+dy) <= radius })
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
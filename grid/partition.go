@@ -0,0 +1,83 @@
+package grid
+
+import "sync"
+
+// Partition is a way of sharing the cells of a grid between threads.
+type Partition int
+
+const (
+	// Rows gives each thread a band of whole rows.
+	Rows Partition = iota
+	// Tiles gives each thread a rectangle, from as square a layout of
+	// tiles as the number of threads allows, e.g., 2 by 2 for 4 threads.
+	// With many threads tiles have fewer cells on their edges than bands
+	// of rows, so fewer neighbors are in other threads' parts.
+	Tiles
+)
+
+// parts caches the parts of each partition of a grid, by partition and
+// number of threads
+type parts struct {
+	mu    sync.Mutex
+	cells map[[2]int][][]int
+}
+
+// Part returns the numbers of the cells thread id of threads works on, in
+// order.  The parts of all of the threads cover every cell once.  The
+// slice must not be modified.
+func (g *Grid[T]) Part(partition Partition, id, threads int) []int {
+	p := &g.parts
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	key := [2]int{int(partition), threads}
+	cells, ok := p.cells[key]
+	if !ok {
+		if p.cells == nil {
+			p.cells = map[[2]int][][]int{}
+		}
+		cells = g.partition(partition, threads)
+		p.cells[key] = cells
+	}
+	return cells[id]
+}
+
+// partition works out the cells of every thread's part
+func (g *Grid[T]) partition(partition Partition, threads int) [][]int {
+	// the number of tiles across and down
+	across, down := 1, threads
+	if partition == Tiles {
+		for rows := 1; rows*rows <= threads; rows++ {
+			if threads%rows == 0 {
+				across, down = threads/rows, rows
+			}
+		}
+	}
+	cells := make([][]int, threads)
+	for id := range cells {
+		x0, x1 := span(g.width, across, id%across)
+		y0, y1 := span(g.height, down, id/across)
+		for y := y0; y < y1; y++ {
+			for x := x0; x < x1; x++ {
+				cells[id] = append(cells[id], g.Index(x, y))
+			}
+		}
+	}
+	return cells
+}
+
+// span returns part i of n of the range 0 to size-1, in equal lengths
+// rounded up as life splits its cells
+func span(size, n, i int) (int, int) {
+	length := size / n
+	if size%n != 0 {
+		length += 1
+	}
+	lo, hi := length*i, length*(i+1)
+	if lo > size {
+		lo = size
+	}
+	if hi > size {
+		hi = size
+	}
+	return lo, hi
+}
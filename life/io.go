@@ -9,20 +9,38 @@ import (
 	"os"
 	"regexp"
 	"strings"
+
+	"github.com/dacb/goabe/plugins"
 )
 
-func (life *matrix) printMatrix(ctx context.Context) error {
-	//time.Sleep(1 * time.Second)
-	fmt.Print("\033[H\033[2J")
+// matrixLines draws the matrix as text, one line per row
+func (life *matrix) matrixLines() []string {
+	lines := make([]string, life.y)
 	for yi := 0; yi < life.y; yi++ {
+		var line strings.Builder
 		for xi := 0; xi < life.x; xi++ {
 			c := '.'
-			if life.mat[xi][yi].alive {
+			if life.cells.Get(xi, yi) {
 				c = 'X'
 			}
-			fmt.Printf(" %c", c)
+			line.WriteString(fmt.Sprintf(" %c", c))
 		}
-		fmt.Printf("\n")
+		lines[yi] = line.String()
+	}
+	return lines
+}
+
+// printMatrix shows the matrix in the engine's dashboard panel named panel
+// if there is one, otherwise it clears the terminal and prints it on stdout
+func (life *matrix) printMatrix(ctx context.Context, panel string) error {
+	if display, ok := ctx.Value("display").(plugins.Display); ok {
+		display.Draw(panel, life.matrixLines())
+		return nil
+	}
+	//time.Sleep(1 * time.Second)
+	fmt.Print("\033[H\033[2J")
+	for _, line := range life.matrixLines() {
+		fmt.Println(line)
 	}
 	return nil
 }
@@ -45,7 +63,7 @@ func (life *matrix) saveMatrix(ctx context.Context, filename string) error {
 		n := 0
 		c = '-'
 		for x := 0; x < life.x; x++ {
-			if life.mat[x][y].alive {
+			if life.cells.Get(x, y) {
 				if c == '-' {
 					n = 1
 					c = 'o'
@@ -185,7 +203,7 @@ func (life *matrix) loadMatrix(ctx context.Context, filename string) error {
 							log.Error(msg)
 							return errors.New("unable to parse the pattern from the file due to invalid sizes")
 						}
-						life.mat[xi][yi].alive = true
+						life.cells.Set(xi, yi, true)
 						xi = xi + 1
 					case 'b':
 						if xi < 0 || xi >= life.x || yi < 0 || yi >= life.y {
@@ -193,7 +211,7 @@ func (life *matrix) loadMatrix(ctx context.Context, filename string) error {
 							log.Error(msg)
 							return errors.New("unable to parse the pattern from the file due to invalid sizes")
 						}
-						life.mat[xi][yi].alive = false
+						life.cells.Set(xi, yi, false)
 						xi = xi + 1
 					case '$':
 						yi = yi + 1
@@ -7,130 +7,134 @@ import (
 	"log/slog"
 	"math/rand"
 
+	"github.com/dacb/goabe/exchange"
+	"github.com/dacb/goabe/grid"
 	"github.com/dacb/goabe/plugins"
-	"github.com/spf13/viper"
 )
 
-type cell struct {
-	alive     bool
-	aliveNext bool
-	neighbors []*cell
+type matrix struct {
+	x, y  int              // dimensions
+	cells *grid.Grid[bool] // whether each cell is alive, double buffered
 }
 
-type matrix struct {
-	x, y      int       // dimensions
-	cells     []cell    // the matrix of cells allocated linearly
-	mat       [][]*cell // a matrix to be addressed by the cell dimension, points to above
-	chunkSize int       // the size of chunks in the matrix for each thread to process
+// Config is the life section of the configuration; the values in
+// Register are the defaults
+type Config struct {
+	InFilename  string `mapstructure:"in_filename" file:"input" desc:"RLE file with the starting pattern, random if empty"`
+	OutFilename string `mapstructure:"out_filename" file:"output" desc:"RLE file to save the final matrix to"`
+	XSize       int    `mapstructure:"x_size" min:"3" desc:"width of the matrix"`
+	YSize       int    `mapstructure:"y_size" min:"3" desc:"height of the matrix"`
+	Boundary    string `mapstructure:"boundary" oneof:"torus,fixed,reflecting,klein" desc:"what lies beyond the edges of the matrix; reflecting mirrors it about the edge cells"`
 }
 
-var life matrix
-var threads int
+// model is the state of one life simulation, so the engine can run
+// several one after the other or at the same time
+type model struct {
+	name    string // instance name, e.g., Life or Life#a
+	config  Config
+	life    matrix
+	threads int
+	rng     *rand.Rand
+}
 
-var rng *rand.Rand
+// New creates an instance of the life plugin with its own state; see
+// plugins.Factory.
+func New(instance string) plugins.Plugin {
+	return (&model{name: instance}).plugin()
+}
+
+func (m *model) plugin() plugins.Plugin {
+	return plugins.Plugin{
+		Init:        m.Init,
+		Name:        func() string { return m.name },
+		Version:     Version,
+		Description: Description,
+		GetHooks:    m.GetHooks,
+		PreRun:      m.PreRun,
+		PostRun:     m.PostRun,
+		Shutdown:    m.Shutdown,
+	}
+}
 
 func Register() {
-	plugins.LoadedPlugins = append(plugins.LoadedPlugins, plugins.Plugin{Init, Name, Version, Description, GetHooks, PreRun, PostRun})
+	plugins.RegisterFactory(Name(), New)
+	plugins.RegisterConfig("life", Config{
+		OutFilename: "OUT.LIF",
+		XSize:       16,
+		YSize:       32,
+		Boundary:    "torus",
+	})
 }
 
 // main initiailization function for the plugin
-func Init(ctx context.Context) error {
+func (m *model) Init(ctx context.Context) error {
 	log, ok := ctx.Value("log").(*slog.Logger)
 	if !ok {
 		return errors.New("no logger found on the current context")
 	}
+	log = log.With("plugin", m.name)
 
 	threadCount, ok := ctx.Value("threads").(int)
 	if !ok {
 		return errors.New("missing number of threads in current context")
 	}
-	threads = threadCount
+	m.threads = threadCount
 
-	// initialize the random seed
-	random_seed := viper.GetInt64("random_seed")
-	rng = rand.New(rand.NewSource((random_seed)))
+	// initialize the random number generator from the engine's seed
+	random_seed, ok := ctx.Value("random_seed").(int64)
+	if !ok {
+		return errors.New("missing random seed in current context")
+	}
+	m.rng = rand.New(rand.NewSource((random_seed)))
 
-	log.Info(fmt.Sprintf("Life plugin Init function was called for %d threads w/ %d as random_seed", threads, random_seed))
+	log.Info(fmt.Sprintf("Life plugin Init function was called for %d threads w/ %d as random_seed", m.threads, random_seed))
 
 	// initialize the data structures for the module
-	//viper.SetDefault("life.in_filename", "IN.LIF")
-	viper.SetDefault("life.out_filename", "OUT.LIF")
-
-	viper.SetDefault("life.x_size", 16)
-	viper.SetDefault("life.y_size", 32)
-	life.x = viper.GetInt("life.x_size")
-	life.y = viper.GetInt("life.y_size")
+	if err := plugins.LoadConfig(plugins.Section(m.name), &m.config); err != nil {
+		log.Error(fmt.Sprintf("unable to decode the %s configuration", plugins.Section(m.name)))
+		return err
+	}
+	life := &m.life
+	life.x = m.config.XSize
+	life.y = m.config.YSize
 
 	if life.x < 3 || life.y < 3 {
 		log.Error("minimum size of matrix must be 3 x 3")
 		return errors.New("minimum size of matrix must be 3 x 3")
 	}
 
-	// allocate the cellular matrix
-	life.cells = make([]cell, life.x*life.y)
-	idx := 0
-	life.mat = make([][]*cell, life.x)
-	for xi := 0; xi < life.x; xi++ {
-		life.mat[xi] = make([]*cell, life.y)
-		for yi := 0; yi < life.y; yi++ {
-			life.mat[xi][yi] = &life.cells[idx]
-			idx += 1
-		}
+	// allocate the cellular matrix, the grid works out each cell's
+	// neighbors once so the hooks need no boundary checks
+	boundary, err := grid.ParseBoundary(m.config.Boundary)
+	if err != nil {
+		log.Error(fmt.Sprintf("unknown boundary '%s'", m.config.Boundary))
+		return err
 	}
-	// now setup the neighbors
-	idx = 0
-	for xi := 0; xi < life.x; xi++ {
-		for yi := 0; yi < life.y; yi++ {
-			// loop over the matrix neighbors and setup the neighbor list
-			// do this using periodic boundaries; doing this once here
-			// allows us to avoid all the ifs when iterating over the neighbors
-			// at the cost of a pointer dereference, should be a good deal
-			// performance wise
-			life.cells[idx].neighbors = make([]*cell, 8)
-			nidx := 0
-			for nxi := -1; nxi <= 1; nxi++ {
-				for nyi := -1; nyi <= 1; nyi++ {
-					// skip outselves as a neighbor
-					if nxi == 0 && nyi == 0 {
-						continue
-					}
-					// calculate neihbor index w/ periodic boundaries
-					nxiPB := nxi + xi
-					if nxiPB < 0 {
-						nxiPB = life.x - 1
-					} else if nxiPB >= life.x {
-						nxiPB = 0
-					}
-					nyiPB := nyi + yi
-					if nyiPB < 0 {
-						nyiPB = life.y - 1
-					} else if nyiPB >= life.y {
-						nyiPB = 0
-					}
-					// set the neighbor in update the index
-					life.cells[idx].neighbors[nidx] = life.mat[nxiPB][nyiPB]
-					nidx += 1
-				}
-			}
-			idx += 1
-		}
+	life.cells, err = grid.New[bool](life.x, life.y, boundary, grid.Moore(1))
+	if err != nil {
+		log.Error("unable to allocate the matrix")
+		return err
 	}
 
 	// initialize the matrix states
-	filename := viper.GetString("life.in_filename")
+	filename := m.config.InFilename
 	if filename != "" {
 		err := life.loadMatrix(ctx, filename)
 		if err != nil {
 			log.Error(fmt.Sprintf("unable to load starting matrix file file '%s'", filename))
 			return err
 		}
-		life.printMatrix(ctx)
+		life.printMatrix(ctx, m.name)
 	} else {
 		aliveCells := 0
-		for idx := 0; idx < life.x*life.y; idx++ {
-			life.cells[idx].alive = rng.Uint32()&(1<<31) == 0 // random true false from integer
-			if life.cells[idx].alive {
-				aliveCells += 1
+		// column by column, so a seed gives the same pattern as it always has
+		for xi := 0; xi < life.x; xi++ {
+			for yi := 0; yi < life.y; yi++ {
+				alive := m.rng.Uint32()&(1<<31) == 0 // random true false from integer
+				life.cells.Set(xi, yi, alive)
+				if alive {
+					aliveCells += 1
+				}
 			}
 		}
 		log.Info(fmt.Sprintf("there are %d alive cells at the start", aliveCells))
@@ -154,72 +158,78 @@ func Description() string {
 	return "Conway's game of plugin for code template"
 }
 
-func GetHooks() []plugins.Hook {
+func (m *model) GetHooks() []plugins.Hook {
 	var hooks []plugins.Hook
-	hooks = append(hooks, plugins.Hook{0, nil, ThreadSubStep0, "thread calculate next state"})
-	hooks = append(hooks, plugins.Hook{1, CoreSubStep1, nil, "core update next state"})
+	hooks = append(hooks, plugins.Hook{SubStep: 0, Thread: m.ThreadSubStep0, Description: "thread calculate next state"})
+	hooks = append(hooks, plugins.Hook{SubStep: 1, Core: m.CoreSubStep1, Description: "core update next state"})
 
 	return hooks
 }
 
 // do before each set of steps
-func PreRun(ctx context.Context) error {
+func (m *model) PreRun(ctx context.Context) error {
 	//log := ctx.Value("log").(*slog.Logger).With("plugin", Name())
 
 	return nil
 }
 
 // do after each set of steps
-func PostRun(ctx context.Context) error {
+func (m *model) PostRun(ctx context.Context) error {
 	//log := ctx.Value("log").(*slog.Logger).With("plugin", Name())
 
-	filename := viper.GetString("life.out_filename")
-	life.saveMatrix(ctx, filename)
+	m.life.saveMatrix(ctx, m.config.OutFilename)
 
 	return nil
 }
 
+// release the matrix so the next Init starts from nothing
+func (m *model) Shutdown(ctx context.Context) error {
+	*m = model{name: m.name}
+	return nil
+}
+
 // note this logs through the context
-func CoreSubStep1(ctx context.Context) error {
-	log := ctx.Value("log").(*slog.Logger).With("plugin", Name())
+func (m *model) CoreSubStep1(ctx context.Context) error {
+	log := ctx.Value("log").(*slog.Logger).With("plugin", m.name)
+	life := &m.life
+	life.cells.Swap()
 	aliveCells := 0
-	for idx := 0; idx < life.x*life.y; idx++ {
-		life.cells[idx].alive = life.cells[idx].aliveNext
-		if life.cells[idx].alive {
+	for _, alive := range life.cells.Cells() {
+		if alive {
 			aliveCells += 1
 		}
 	}
 	log.Info(fmt.Sprintf("%d alive cells", aliveCells))
-	life.printMatrix(ctx)
+	if metrics, ok := ctx.Value("metrics").(plugins.Metrics); ok {
+		metrics.Set(plugins.Section(m.name)+".alive_cells", float64(aliveCells))
+	}
+	// share the count with the other plugins
+	if data, ok := ctx.Value("data").(*exchange.Registry); ok {
+		if err := data.Set(plugins.Section(m.name)+".alive_cells", aliveCells); err != nil {
+			return err
+		}
+	}
+	life.printMatrix(ctx, m.name)
 	return nil
 }
 
 // note this logs through the context
-func ThreadSubStep0(ctx context.Context, id int, name string) error {
+func (m *model) ThreadSubStep0(ctx context.Context, id int, name string) error {
 	//log := ctx.Value("log").(*slog.Logger).With("plugin", Name())
+	cells := m.life.cells
 
-	// determine the chunksize
-	chunkSize := (life.x * life.y) / threads
-	if (life.x*life.y)%threads != 0 {
-		chunkSize += 1
-	}
-	// iterative over this thread's chunk
-	for idx := chunkSize * id; idx <= chunkSize*(id+1) && idx < life.x*life.y; idx++ {
+	// iterate over this thread's rows
+	for _, idx := range cells.Part(grid.Rows, id, m.threads) {
 		alive := 0
-		for nidx := 0; nidx < 8; nidx++ {
-			if life.cells[idx].neighbors[nidx].alive {
+		for _, nidx := range cells.Neighbors(idx) {
+			if cells.At(nidx) {
 				alive += 1
 			}
 		}
-		life.cells[idx].aliveNext = false
-		if life.cells[idx].alive {
-			if alive == 2 || alive == 3 {
-				life.cells[idx].aliveNext = true
-			}
+		if cells.At(idx) {
+			cells.SetNext(idx, alive == 2 || alive == 3)
 		} else {
-			if alive == 3 {
-				life.cells[idx].aliveNext = true
-			}
+			cells.SetNext(idx, alive == 3)
 		}
 	}
 
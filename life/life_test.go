@@ -0,0 +1,226 @@
+package life
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dacb/goabe/goabetest"
+)
+
+func TestMain(m *testing.M) {
+	Register()
+	os.Exit(m.Run())
+}
+
+// alive copies the state of the matrix, indexed by x then y
+func alive(m *model) [][]bool {
+	life := &m.life
+	state := make([][]bool, life.x)
+	for x := range state {
+		state[x] = make([]bool, life.y)
+		for y := range state[x] {
+			state[x][y] = life.cells.Get(x, y)
+		}
+	}
+	return state
+}
+
+func equal(a, b [][]bool) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for x := range a {
+		for y := range a[x] {
+			if a[x][y] != b[x][y] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// pattern writes an RLE file for a test
+func pattern(t *testing.T, rle string) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), "in.rle")
+	if err := os.WriteFile(filename, []byte(rle), 0666); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
+
+// newLife starts a new instance of the plugin in a harness
+func newLife(t *testing.T, x, y int, options ...goabetest.Option) (*goabetest.Harness, *model) {
+	options = append([]goabetest.Option{
+		goabetest.Set("life.x_size", x),
+		goabetest.Set("life.y_size", y),
+		goabetest.Set("life.out_filename", filepath.Join(t.TempDir(), "out.rle")),
+	}, options...)
+	m := &model{name: Name()}
+	return goabetest.Start(t, m.plugin(), options...), m
+}
+
+func TestBlinkerOscillates(t *testing.T) {
+	h, m := newLife(t, 5, 5, goabetest.Threads(2),
+		goabetest.Set("life.in_filename", pattern(t, "x = 3, y = 3\n$3o$!\n")))
+	start := alive(m)
+	if !start[0][1] || !start[1][1] || !start[2][1] {
+		t.Fatalf("the blinker was not loaded: %v", start)
+	}
+	h.Step()
+	vertical := alive(m)
+	if equal(start, vertical) {
+		t.Fatal("the blinker did not change in one step")
+	}
+	if !vertical[1][0] || !vertical[1][1] || !vertical[1][2] {
+		t.Errorf("the blinker is not vertical after one step: %v", vertical)
+	}
+	h.Step()
+	if !equal(start, alive(m)) {
+		t.Error("the blinker did not return to its start after two steps")
+	}
+	for step := int64(0); step < h.Steps(); step++ {
+		if count, ok := h.Metric("life.alive_cells", step); !ok || count != 3 {
+			t.Errorf("step %d: %v alive cells, want 3", step, count)
+		}
+	}
+	h.Finish()
+}
+
+func TestPublishesAliveCells(t *testing.T) {
+	h, _ := newLife(t, 5, 5, goabetest.Set("life.in_filename", pattern(t, "x = 3, y = 3\n$3o$!\n")))
+	h.Run(2)
+	alive, step, ok := h.Data().Lookup("life.alive_cells")
+	if !ok || alive != 3 || step != 1 {
+		t.Errorf("published %v at step %d, want 3 at step 1", alive, step)
+	}
+	h.Finish()
+}
+
+func TestFixedBoundary(t *testing.T) {
+	// a blinker against the left edge only wraps around on a torus
+	line := "x = 1, y = 4\nb$o$o$o!\n"
+	for boundary, want := range map[string]float64{"torus": 3, "fixed": 2} {
+		h, _ := newLife(t, 5, 5, goabetest.Set("life.boundary", boundary),
+			goabetest.Set("life.in_filename", pattern(t, line)))
+		h.Step()
+		if count, _ := h.Metric("life.alive_cells", 0); count != want {
+			t.Errorf("%s: %v alive cells after a step, want %v", boundary, count, want)
+		}
+		h.Finish()
+	}
+}
+
+func TestReflectingBoundary(t *testing.T) {
+	// the grid is mirrored about its edge cells, so a blinker in the left
+	// column is a whole blinker and the cells in it do not count themselves
+	h, m := newLife(t, 5, 9, goabetest.Set("life.boundary", "reflecting"),
+		goabetest.Set("life.in_filename", pattern(t, "x = 1, y = 6\n3$o$o$o!\n")))
+	start := alive(m)
+	h.Step()
+	horizontal := alive(m)
+	for x := 0; x < 5; x++ {
+		for y := 0; y < 9; y++ {
+			if horizontal[x][y] != (y == 4 && x <= 1) {
+				t.Fatalf("the blinker is not half of a horizontal one after a step: %v", horizontal)
+			}
+		}
+	}
+	h.Step()
+	if !equal(start, alive(m)) {
+		t.Error("the blinker did not return to its start after two steps")
+	}
+	h.Finish()
+}
+
+func TestGliderMoves(t *testing.T) {
+	h, m := newLife(t, 8, 8, goabetest.Set("life.in_filename", pattern(t, "x = 3, y = 3\nbo$2bo$3o!\n")))
+	start := alive(m)
+	h.Run(4)
+	moved := alive(m)
+	for x := 0; x < 8; x++ {
+		for y := 0; y < 8; y++ {
+			if moved[(x+1)%8][(y+1)%8] != start[x][y] {
+				t.Fatalf("the glider did not move by (1, 1) in 4 steps")
+			}
+		}
+	}
+	h.Finish()
+}
+
+func TestThreadCountDoesNotChangeResult(t *testing.T) {
+	var reference [][]bool
+	for _, threads := range []int{1, 2, 3, 7} {
+		h, m := newLife(t, 17, 13, goabetest.Threads(threads), goabetest.Seed(42))
+		h.Run(10)
+		if reference == nil {
+			reference = alive(m)
+		} else if !equal(reference, alive(m)) {
+			t.Errorf("%d threads gave a different matrix than 1 thread", threads)
+		}
+		h.Finish()
+	}
+}
+
+func TestSavedMatrixReloads(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "saved.rle")
+	h, m := newLife(t, 12, 9, goabetest.Seed(7), goabetest.Set("life.out_filename", out))
+	h.Run(3)
+	saved := alive(m)
+	h.Finish()
+
+	_, reloaded := newLife(t, 12, 9, goabetest.Set("life.in_filename", out))
+	if !equal(saved, alive(reloaded)) {
+		t.Error("the reloaded matrix differs from the saved one")
+	}
+}
+
+func TestDrawsToDisplay(t *testing.T) {
+	h, _ := newLife(t, 4, 3)
+	h.Step()
+	if lines := h.Panel(Name()); len(lines) != 3 {
+		t.Errorf("drew %d lines, want 3", len(lines))
+	}
+}
+
+func TestShutdownResetsState(t *testing.T) {
+	h, m := newLife(t, 6, 6)
+	h.Run(2)
+	h.Finish()
+	if m.life.cells != nil || m.rng != nil {
+		t.Error("Shutdown left the matrix or random generator in place")
+	}
+	// the same instance starts afresh when initialized again
+	h = goabetest.Start(t, m.plugin(), goabetest.Set("life.x_size", 7), goabetest.Set("life.y_size", 4),
+		goabetest.Set("life.out_filename", filepath.Join(t.TempDir(), "out.rle")))
+	if m.life.x != 7 || m.life.cells.Len() != 7*4 {
+		t.Errorf("the second Init made a %d by %d matrix with %d cells", m.life.x, m.life.y, m.life.cells.Len())
+	}
+	h.Finish()
+}
+
+func TestConcurrentInstancesAreIndependent(t *testing.T) {
+	_, reference := newLife(t, 10, 10, goabetest.Seed(3))
+	_, other := newLife(t, 10, 10, goabetest.Seed(3))
+	h1, m1 := newLife(t, 10, 10, goabetest.Seed(3), goabetest.Threads(2))
+	h2, m2 := newLife(t, 10, 10, goabetest.Seed(99), goabetest.Threads(3))
+	if !equal(alive(reference), alive(other)) {
+		t.Fatal("two instances with the same seed started differently")
+	}
+	done := make(chan bool)
+	go func() {
+		defer close(done)
+		h2.Run(5)
+	}()
+	h1.Run(5)
+	<-done
+	if equal(alive(m1), alive(m2)) {
+		t.Error("instances with different seeds ended the same")
+	}
+	h3, m3 := newLife(t, 10, 10, goabetest.Seed(3))
+	h3.Run(5)
+	if !equal(alive(m1), alive(m3)) {
+		t.Error("running another instance at the same time changed the result")
+	}
+}
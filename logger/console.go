@@ -0,0 +1,36 @@
+package logger
+
+import (
+	"io"
+	"sync"
+)
+
+// consoleWriter is the writer behind the console stream.  It can be pointed
+// elsewhere after the loggers have been built, e.g., into the dashboard's
+// log pane while it owns the terminal.
+type consoleWriter struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func (w *consoleWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.out.Write(p)
+}
+
+var console = &consoleWriter{}
+
+// SetConsoleWriter sends the console stream to out until the returned
+// function is called to restore the previous writer.
+func SetConsoleWriter(out io.Writer) func() {
+	console.mu.Lock()
+	defer console.mu.Unlock()
+	previous := console.out
+	console.out = out
+	return func() {
+		console.mu.Lock()
+		defer console.mu.Unlock()
+		console.out = previous
+	}
+}
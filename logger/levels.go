@@ -0,0 +1,165 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+)
+
+// The sinks that have their own threshold level.  A subsystem level from
+// log_levels overrides the sink level for records from that subsystem.
+const (
+	FileSink    = "file"
+	ConsoleSink = "console"
+)
+
+// levelTable holds the active threshold levels.  It is read on every log
+// call from every thread and may be changed at runtime, hence the lock.
+type levelTable struct {
+	mu         sync.RWMutex
+	sinks      map[string]slog.Level // threshold per sink
+	subsystems map[string]slog.Level // overrides per plugin or actor
+}
+
+var levels = &levelTable{
+	sinks:      map[string]slog.Level{},
+	subsystems: map[string]slog.Level{},
+}
+
+// level returns the threshold for a record logged to sink by a logger
+// tagged with the given subsystem names, most specific first.
+func (t *levelTable) level(sink string, subsystems ...string) slog.Level {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	for _, subsystem := range subsystems {
+		if subsystem == "" {
+			continue
+		}
+		if level, ok := t.subsystems[subsystem]; ok {
+			return level
+		}
+	}
+	return t.sinks[sink]
+}
+
+// ParseLevel converts a level name such as DEBUG or warn into a slog.Level.
+func ParseLevel(text string) (slog.Level, error) {
+	var level slog.Level
+	err := level.UnmarshalText([]byte(strings.ToUpper(text)))
+	if err != nil {
+		return level, fmt.Errorf("invalid log level '%s'", text)
+	}
+	return level, nil
+}
+
+// SetLevel changes the threshold of a subsystem (a plugin name, 'core',
+// 'thread' or a specific actor such as 'thread_3') while running.
+func SetLevel(subsystem string, level slog.Level) {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	levels.subsystems[strings.ToLower(subsystem)] = level
+}
+
+// SetSinkLevel changes the default threshold of a sink while running.
+func SetSinkLevel(sink string, level slog.Level) {
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	levels.sinks[sink] = level
+}
+
+// LoadLevels (re)reads log_level, log_file_level, log_console_level and the
+// log_levels map from the configuration and replaces the active levels.
+// Nothing is changed if any of the levels fails to parse.
+func LoadLevels() error {
+	return loadLevels(viper.GetViper())
+}
+
+func loadLevels(v *viper.Viper) error {
+	base, err := ParseLevel(v.GetString("log_level"))
+	if err != nil {
+		return err
+	}
+	sinks := map[string]slog.Level{FileSink: base, ConsoleSink: base}
+	for sink, key := range map[string]string{FileSink: "log_file_level", ConsoleSink: "log_console_level"} {
+		if text := v.GetString(key); text != "" {
+			level, err := ParseLevel(text)
+			if err != nil {
+				return fmt.Errorf("%s: %w", key, err)
+			}
+			sinks[sink] = level
+		}
+	}
+	subsystems := map[string]slog.Level{}
+	for subsystem, text := range v.GetStringMapString("log_levels") {
+		level, err := ParseLevel(text)
+		if err != nil {
+			return fmt.Errorf("log_levels.%s: %w", subsystem, err)
+		}
+		subsystems[strings.ToLower(subsystem)] = level
+	}
+
+	levels.mu.Lock()
+	defer levels.mu.Unlock()
+	levels.sinks = sinks
+	levels.subsystems = subsystems
+	return nil
+}
+
+// levelHandler filters the records going to one sink using the level table.
+// It remembers the plugin and actor attributes added to the logger with
+// With() so that the subsystem is known before a record is even built.
+type levelHandler struct {
+	sink    string
+	plugin  string
+	actor   string
+	grouped bool
+	next    slog.Handler
+}
+
+func newLevelHandler(sink string, next slog.Handler) *levelHandler {
+	return &levelHandler{sink: sink, next: next}
+}
+
+// actorClass maps thread_N to thread so all threads can be set at once.
+func actorClass(actor string) string {
+	if i := strings.LastIndexByte(actor, '_'); i > 0 {
+		return actor[:i]
+	}
+	return actor
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return level >= levels.level(h.sink, h.plugin, h.actor, actorClass(h.actor))
+}
+
+func (h *levelHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.next.Handle(ctx, r)
+}
+
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	nh := *h
+	nh.next = h.next.WithAttrs(attrs)
+	// attributes inside a group are not the plugin or actor tags
+	if !h.grouped {
+		for _, attr := range attrs {
+			switch attr.Key {
+			case "plugin":
+				nh.plugin = strings.ToLower(attr.Value.String())
+			case "actor":
+				nh.actor = strings.ToLower(attr.Value.String())
+			}
+		}
+	}
+	return &nh
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	nh := *h
+	nh.next = h.next.WithGroup(name)
+	nh.grouped = true
+	return &nh
+}
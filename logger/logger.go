@@ -1,8 +1,12 @@
 package logger
 
 import (
+	"fmt"
 	"log/slog"
+	"math"
 	"os"
+	"path/filepath"
+	"time"
 
 	slogmulti "github.com/samber/slog-multi"
 	"github.com/spf13/viper"
@@ -11,6 +15,13 @@ import (
 // This is the system wide log interface.
 var Log *slog.Logger
 
+// RunID identifies this invocation of goabe.  It comes from the run_id
+// configuration value or is generated from the start time and process id.
+var RunID string
+
+// LogPath is the path of the JSON log file, empty if the file sink is off.
+var LogPath string
+
 // InitLogger reads configuration to initialize logging for goabe.
 // The function needs the viper configuration system to be available
 // which also needs the cobra command line parser to be availabel.
@@ -18,28 +29,125 @@ var Log *slog.Logger
 // this with init().
 // When this function is run, the configuration will already have been
 // read from whatever file specified by the command line arguments.
-// This tooling runs dual logging streams: one on the stdout for text
-// based logging and the other on the json file.
+// This tooling runs dual logging streams: one on the console
+// (log_console of stdout or stderr, log_console_format of text, json,
+// pretty or none) and the other on the json file (log_file, or no file
+// if it is empty).
+// Each stream has its own threshold (log_console_level and log_file_level,
+// both defaulting to log_level) and the log_levels map can override these
+// per plugin or actor, e.g., {"life": "DEBUG", "core": "WARN"}.  The levels
+// are reloaded from the configuration file on SIGHUP.
 // This function will clobber (i.e., overwrite and truncate) any
-// existing contents of the log file.
-func InitLogger() {
-	// initialize the system using the config data from viper
-	logfile, err := os.OpenFile(viper.GetString("log_file"), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
-	if err != nil {
-		panic(err)
-	}
-	// fanout over the stdout in text and output log in json
-	log_level_text := []byte(viper.GetString("log_level"))
-	var log_level slog.Level
-	log_level.UnmarshalText(log_level_text)
+// existing contents of the log file unless log_file_append is set.  The
+// file is rotated by size (log_file_max_size in MB) and/or by age
+// (log_file_max_age, e.g. "24h"), keeping log_file_max_backups of the old
+// files and gzipping them if log_file_compress is set.  With log_run_dir,
+// the file is placed in a subdirectory named by the run id so concurrent
+// batch jobs do not clobber each other.
+// Commands that write their results to stdout (e.g., reading a log) set
+// output, which sends the console stream to stderr as text and leaves the
+// log file alone so that it is not clobbered by the command reading it.
+func InitLogger(output bool) {
+	// a bad level should not stop a command that reports on the
+	// configuration, so these log at the default level instead
+	levelsErr := LoadLevels()
+	if levelsErr != nil && !output {
+		panic(levelsErr)
+	}
+
+	RunID = viper.GetString("run_id")
+	if RunID == "" {
+		RunID = fmt.Sprintf("%s-%d", time.Now().Format("20060102-150405"), os.Getpid())
+	}
+
+	// the sinks themselves accept everything and the level handlers do
+	// the filtering
 	opts := &slog.HandlerOptions{
-		Level: log_level,
-	}
-	Log = slog.New(
-		slogmulti.Fanout(
-			slog.NewJSONHandler(logfile, opts),
-			slog.NewTextHandler(os.Stdout, opts),
-		),
-	)
-	Log.Info("logging initialized")
+		Level: slog.Level(math.MinInt),
+	}
+	var handlers []slog.Handler
+
+	// initialize the system using the config data from viper
+	LogPath = viper.GetString("log_file")
+	if output {
+		LogPath = ""
+	}
+	if LogPath != "" {
+		var err error
+		LogPath, err = RunPath(LogPath)
+		if err != nil {
+			panic(err)
+		}
+		logfile, err := openRotatingFile(LogPath,
+			viper.GetBool("log_file_append"),
+			viper.GetInt64("log_file_max_size")*1024*1024,
+			viper.GetDuration("log_file_max_age"),
+			viper.GetInt("log_file_max_backups"),
+			viper.GetBool("log_file_compress"),
+		)
+		if err != nil {
+			panic(err)
+		}
+		handlers = append(handlers, newLevelHandler(FileSink, slog.NewJSONHandler(logfile, opts)))
+	}
+
+	consoleName := viper.GetString("log_console")
+	consoleFormat := viper.GetString("log_console_format")
+	if output {
+		consoleName, consoleFormat = "stderr", "text"
+	}
+	switch consoleName {
+	case "", "stdout":
+		console.out = os.Stdout
+	case "stderr":
+		console.out = os.Stderr
+	default:
+		panic(fmt.Errorf("invalid log_console '%s', expecting stdout or stderr", consoleName))
+	}
+	switch consoleFormat {
+	case "", "text":
+		handlers = append(handlers, newLevelHandler(ConsoleSink, slog.NewTextHandler(console, opts)))
+	case "json":
+		handlers = append(handlers, newLevelHandler(ConsoleSink, slog.NewJSONHandler(console, opts)))
+	case "pretty":
+		handlers = append(handlers, newLevelHandler(ConsoleSink, newPrettyHandler(console, isTerminal(console.out))))
+	case "none":
+	default:
+		panic(fmt.Errorf("invalid log_console_format '%s', expecting text, json, pretty or none", consoleFormat))
+	}
+
+	// fanout over the console and the output log
+	Log = slog.New(slogmulti.Fanout(handlers...))
+	watchLevels()
+	Log.With("run_id", RunID).With("log_file", LogPath).Info("logging initialized")
+	if levelsErr != nil {
+		Log.Warn(levelsErr.Error())
+	}
+}
+
+// FindRunPath returns where RunPath put filename for the run with the id,
+// without creating anything, e.g., to find the files of an earlier run.
+func FindRunPath(filename, id string) string {
+	if !viper.GetBool("log_run_dir") {
+		return filename
+	}
+	return runFile(filename, id)
+}
+
+func runFile(filename, id string) string {
+	return filepath.Join(filepath.Dir(filename), id, filepath.Base(filename))
+}
+
+// RunPath returns where a file of the run named filename should go.  With
+// log_run_dir set, this is in the run id subdirectory of the directory of
+// filename, which is created if needed.  Otherwise it is filename as is.
+func RunPath(filename string) (string, error) {
+	if !viper.GetBool("log_run_dir") {
+		return filename, nil
+	}
+	path := runFile(filename, RunID)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return "", err
+	}
+	return path, nil
 }
@@ -0,0 +1,126 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+)
+
+// ANSI escape codes used by the pretty console format
+const (
+	colorReset = "\033[0m"
+	colorDim   = "\033[2m"
+	colorRed   = "\033[31m"
+	colorGreen = "\033[32m"
+	colorYel   = "\033[33m"
+	colorGray  = "\033[90m"
+)
+
+// prettyHandler writes compact, human oriented lines to a console:
+// the time of day, the level, the message and then the attributes.
+// Levels are colored if color is set, normally when the output is a terminal.
+type prettyHandler struct {
+	mu     *sync.Mutex
+	out    io.Writer
+	color  bool
+	attrs  string // preformatted attributes from WithAttrs
+	prefix string // current group prefix, e.g. "cmd."
+}
+
+func newPrettyHandler(out io.Writer, color bool) *prettyHandler {
+	return &prettyHandler{mu: new(sync.Mutex), out: out, color: color}
+}
+
+// isTerminal reports if out is a character device, i.e., not a file or pipe
+func isTerminal(out io.Writer) bool {
+	file, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+func (h *prettyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true
+}
+
+func (h *prettyHandler) paint(color, text string) string {
+	if !h.color {
+		return text
+	}
+	return color + text + colorReset
+}
+
+func (h *prettyHandler) levelColor(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return colorRed
+	case level >= slog.LevelWarn:
+		return colorYel
+	case level >= slog.LevelInfo:
+		return colorGreen
+	default:
+		return colorGray
+	}
+}
+
+func (h *prettyHandler) appendAttr(buf *bytes.Buffer, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			prefix += attr.Key + "."
+		}
+		for _, a := range attr.Value.Group() {
+			h.appendAttr(buf, prefix, a)
+		}
+		return
+	}
+	fmt.Fprintf(buf, " %s%s", h.paint(colorDim, prefix+attr.Key+"="), attr.Value.String())
+}
+
+func (h *prettyHandler) Handle(ctx context.Context, r slog.Record) error {
+	buf := new(bytes.Buffer)
+	fmt.Fprintf(buf, "%s %s %s%s",
+		h.paint(colorDim, r.Time.Format("15:04:05.000")),
+		h.paint(h.levelColor(r.Level), fmt.Sprintf("%-5s", r.Level.String())),
+		r.Message,
+		h.attrs,
+	)
+	r.Attrs(func(attr slog.Attr) bool {
+		h.appendAttr(buf, h.prefix, attr)
+		return true
+	})
+	buf.WriteByte('\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := h.out.Write(buf.Bytes())
+	return err
+}
+
+func (h *prettyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	nh := *h
+	buf := bytes.NewBufferString(h.attrs)
+	for _, attr := range attrs {
+		h.appendAttr(buf, h.prefix, attr)
+	}
+	nh.attrs = buf.String()
+	return &nh
+}
+
+func (h *prettyHandler) WithGroup(name string) slog.Handler {
+	nh := *h
+	nh.prefix = h.prefix + name + "."
+	return &nh
+}
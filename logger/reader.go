@@ -0,0 +1,107 @@
+package logger
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Record is one line of the JSON log file written by InitLogger with the
+// attributes that the engine uses pulled out for filtering.
+type Record struct {
+	Time       time.Time
+	Level      slog.Level
+	Msg        string
+	Plugin     string         // plugin attribute, lower case, if any
+	Actor      string         // actor attribute (core, thread_N), if any
+	Step       int64          // step attribute, valid if HasStep
+	HasStep    bool           // true if the record has a step attribute
+	RunTime    time.Duration  // run_time attribute, valid if HasRunTime
+	HasRunTime bool           // true if the record has a run_time attribute
+	Attrs      map[string]any // all of the decoded attributes
+	Line       string         // the raw JSON line
+}
+
+// IsStepEnd reports if the record is the core's end of step report.
+func (r *Record) IsStepEnd() bool {
+	return r.Msg == "finished" && r.Actor == "core" && r.HasStep && r.HasRunTime
+}
+
+// IsRunEnd reports if the record is the core's end of run report.
+func (r *Record) IsRunEnd() bool {
+	return r.Msg == "finished" && r.Actor == "core" && !r.HasStep && r.HasRunTime
+}
+
+// ReadRecords parses the JSON log from in and calls fn for each record.
+// Reading stops at the first error from fn or at an unparsable line.
+func ReadRecords(in io.Reader, fn func(*Record) error) error {
+	scanner := bufio.NewScanner(in)
+	// log lines can be long when plugins log large values
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line += 1
+		text := scanner.Text()
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		record, err := parseRecord(text)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+func parseRecord(text string) (*Record, error) {
+	decoder := json.NewDecoder(strings.NewReader(text))
+	decoder.UseNumber()
+	attrs := map[string]any{}
+	if err := decoder.Decode(&attrs); err != nil {
+		return nil, err
+	}
+	r := &Record{Attrs: attrs, Line: text}
+
+	if value, ok := attrs[slog.TimeKey].(string); ok {
+		t, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, err
+		}
+		r.Time = t
+	}
+	if value, ok := attrs[slog.LevelKey].(string); ok {
+		level, err := ParseLevel(value)
+		if err != nil {
+			return nil, err
+		}
+		r.Level = level
+	}
+	r.Msg, _ = attrs[slog.MessageKey].(string)
+	if value, ok := attrs["plugin"].(string); ok {
+		r.Plugin = strings.ToLower(value)
+	}
+	r.Actor, _ = attrs["actor"].(string)
+	if value, ok := attrs["step"].(json.Number); ok {
+		step, err := value.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid step: %w", err)
+		}
+		r.Step, r.HasStep = step, true
+	}
+	// durations are written by the JSON handler as integer nanoseconds
+	if value, ok := attrs["run_time"].(json.Number); ok {
+		ns, err := value.Int64()
+		if err != nil {
+			return nil, fmt.Errorf("invalid run_time: %w", err)
+		}
+		r.RunTime, r.HasRunTime = time.Duration(ns), true
+	}
+	return r, nil
+}
@@ -0,0 +1,161 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+)
+
+// backupLayout is the timestamp format of the suffix of rotated files
+const backupLayout = "20060102T150405.000"
+
+// backupSuffix matches the suffix rotate gives the files it moves aside, so
+// prune leaves other files that share the log's name alone
+var backupSuffix = regexp.MustCompile(`^\.\d{8}T\d{6}\.\d{3}(\.gz)?$`)
+
+// rotatingFile is an io.Writer over a log file that is moved aside once it
+// grows past maxSize bytes or has been open longer than maxAge.  The old
+// file is renamed with a timestamp suffix, optionally gzipped, and only the
+// newest maxBackups of them are kept.  A zero limit disables that check.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	append     bool
+	maxSize    int64
+	maxAge     time.Duration
+	maxBackups int
+	compress   bool
+
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+func openRotatingFile(path string, append bool, maxSize int64, maxAge time.Duration, maxBackups int, compress bool) (*rotatingFile, error) {
+	r := &rotatingFile{
+		path:       path,
+		append:     append,
+		maxSize:    maxSize,
+		maxAge:     maxAge,
+		maxBackups: maxBackups,
+		compress:   compress,
+	}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *rotatingFile) open() error {
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if r.append {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+	}
+	file, err := os.OpenFile(r.path, flags, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	r.opened = time.Now()
+	return nil
+}
+
+func (r *rotatingFile) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.size > 0 && ((r.maxSize > 0 && r.size+int64(len(p)) > r.maxSize) ||
+		(r.maxAge > 0 && time.Since(r.opened) >= r.maxAge)) {
+		if err := r.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	return n, err
+}
+
+// rotate must be called with the lock held
+func (r *rotatingFile) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	backup := fmt.Sprintf("%s.%s", r.path, time.Now().Format(backupLayout))
+	if err := os.Rename(r.path, backup); err != nil {
+		return err
+	}
+	// a rotated file always starts empty, regardless of the append setting
+	r.append = false
+	if err := r.open(); err != nil {
+		return err
+	}
+	if r.compress {
+		if err := compressFile(backup); err != nil {
+			return err
+		}
+	}
+	return r.prune()
+}
+
+// prune removes all but the newest maxBackups rotated files
+func (r *rotatingFile) prune() error {
+	if r.maxBackups <= 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(r.path + ".*")
+	if err != nil {
+		return err
+	}
+	var backups []string
+	for _, match := range matches {
+		if backupSuffix.MatchString(match[len(r.path):]) {
+			backups = append(backups, match)
+		}
+	}
+	// the timestamp suffix sorts chronologically
+	sort.Strings(backups)
+	for len(backups) > r.maxBackups {
+		if err := os.Remove(backups[0]); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+func compressFile(filename string) error {
+	in, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(filename + ".gz")
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	if _, err := io.Copy(gz, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(filename)
+}
@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFile(t *testing.T) {
+	for _, test := range []struct {
+		name       string
+		maxSize    int64
+		maxBackups int
+		compress   bool
+		writes     int
+		backups    int
+	}{
+		{name: "under the size", maxSize: 100, writes: 5, backups: 0},
+		{name: "by size", maxSize: 10, writes: 4, backups: 3},
+		{name: "by size and count", maxSize: 10, maxBackups: 2, writes: 6, backups: 2},
+		{name: "compressed", maxSize: 10, maxBackups: 1, compress: true, writes: 3, backups: 1},
+		{name: "never", writes: 5, backups: 0},
+	} {
+		t.Run(test.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "goabe.log.json")
+			// files that share the log's name but were not rotated by it
+			others := []string{"goabe.log.json.old", "goabe.log.json.20260101", "goabe.log.json.20260101T000000.000.bak"}
+			for _, name := range others {
+				if err := os.WriteFile(filepath.Join(dir, name), nil, 0666); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r, err := openRotatingFile(path, false, test.maxSize, 0, test.maxBackups, test.compress)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i := 0; i < test.writes; i++ {
+				if _, err := r.Write([]byte("12345678\n")); err != nil {
+					t.Fatal(err)
+				}
+				// the backups are named by the millisecond they were rotated
+				time.Sleep(2 * time.Millisecond)
+			}
+			r.file.Close()
+
+			var backups []string
+			for _, match := range mustGlob(t, path+".*") {
+				if backupSuffix.MatchString(strings.TrimPrefix(match, path)) {
+					backups = append(backups, match)
+				}
+			}
+			if len(backups) != test.backups {
+				t.Errorf("%d rotated files, want %d: %v", len(backups), test.backups, backups)
+			}
+			for _, backup := range backups {
+				if strings.HasSuffix(backup, ".gz") != test.compress {
+					t.Errorf("%s is not compressed as configured", backup)
+				}
+			}
+			for _, name := range others {
+				if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+					t.Errorf("%s was removed", name)
+				}
+			}
+			if info, err := os.Stat(path); err != nil || info.Size() == 0 {
+				t.Errorf("the log file is missing or empty")
+			}
+		})
+	}
+}
+
+func mustGlob(t *testing.T, pattern string) []string {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return matches
+}
@@ -0,0 +1,58 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/spf13/viper"
+)
+
+var watchOnce sync.Once
+
+// ReadConfig reads the configuration again for reloading the log levels.
+// The command sets it to read the file the way it was read at start up, with
+// the files it extends, the profile, the environment and the overrides.
+var ReadConfig func() (*viper.Viper, error)
+
+// watchLevels reloads the log levels from the configuration file each time
+// the process receives SIGHUP, e.g., after editing log_levels in goabe.json
+// during a long run and then running `kill -HUP <pid>`.  The configuration
+// is read into a separate viper instance by ReadConfig so the running
+// engine's configuration is left untouched; only the levels change.
+func watchLevels() {
+	watchOnce.Do(func() {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGHUP)
+		go func() {
+			for range sigs {
+				reloadLevels()
+			}
+		}()
+	})
+}
+
+func reloadLevels() {
+	log := Log.With("actor", "logger")
+	filename := viper.ConfigFileUsed()
+	if filename == "" || ReadConfig == nil {
+		log.Warn("received SIGHUP but no configuration file is in use; log levels unchanged")
+		return
+	}
+	v, err := ReadConfig()
+	if err != nil {
+		log.With("config_file", filename).Error(fmt.Sprintf("unable to reread configuration file for log levels: %v", err))
+		return
+	}
+	// keep the current values for any level that the configuration does not set
+	for _, key := range []string{"log_level", "log_file_level", "log_console_level"} {
+		v.SetDefault(key, viper.GetString(key))
+	}
+	if err := loadLevels(v); err != nil {
+		log.With("config_file", filename).Error(err.Error())
+		return
+	}
+	log.With("config_file", filename).Info("log levels reloaded")
+}
@@ -0,0 +1,124 @@
+// Package manifest records what produced the results of a run so that the
+// run can be identified and reproduced, and so its outputs can be checked
+// against it later.
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dacb/goabe/buildinfo"
+)
+
+// Manifest is written by the engine at the end of every run.
+type Manifest struct {
+	RunID       string         `json:"run_id"`
+	Build       buildinfo.Info `json:"build"`
+	Plugins     []Plugin       `json:"plugins"`
+	Seed        int64          `json:"seed"`
+	Threads     int            `json:"threads"`
+	Steps       int64          `json:"steps"`
+	ConfigFile  string         `json:"config_file"`
+	Config      map[string]any `json:"config"` // the effective configuration
+	CommandLine []string       `json:"command_line"`
+	Host        string         `json:"host"`
+	WorkDir     string         `json:"work_dir"` // relative file paths are from here
+	StartTime   time.Time      `json:"start_time"`
+	EndTime     time.Time      `json:"end_time"`
+	Inputs      []File         `json:"inputs"`
+	Outputs     []File         `json:"outputs"`
+}
+
+// Plugin identifies a plugin that took part in the run.
+type Plugin struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// File is an input or output of the run with its checksum.
+type File struct {
+	Key    string `json:"key,omitempty"` // configuration key naming the file
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Checksum returns the size and SHA-256 of the file at path.
+func Checksum(key, path string) (File, error) {
+	f := File{Key: key, Path: path}
+	file, err := os.Open(path)
+	if err != nil {
+		return f, err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	f.Size, err = io.Copy(hash, file)
+	if err != nil {
+		return f, err
+	}
+	f.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	return f, nil
+}
+
+// Problem is a file that no longer matches the manifest.
+type Problem struct {
+	File    File
+	Problem string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.File.Path, p.Problem)
+}
+
+// Verify checks the inputs and outputs of the manifest against the files on
+// disk and returns those that are missing or changed.  Relative paths are
+// looked for from the run's working directory, then from dir, normally the
+// directory of the manifest, for runs that were moved.
+func (m *Manifest) Verify(dir string) []Problem {
+	var problems []Problem
+	for _, recorded := range append(append([]File{}, m.Inputs...), m.Outputs...) {
+		path := recorded.Path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(m.WorkDir, recorded.Path)
+			if _, err := os.Stat(path); err != nil {
+				path = filepath.Join(dir, filepath.Base(recorded.Path))
+			}
+		}
+		current, err := Checksum(recorded.Key, path)
+		switch {
+		case err != nil:
+			problems = append(problems, Problem{recorded, fmt.Sprintf("unreadable: %v", err)})
+		case current.Size != recorded.Size || current.SHA256 != recorded.SHA256:
+			problems = append(problems, Problem{recorded, fmt.Sprintf("changed, sha256 %s is now %s", recorded.SHA256, current.SHA256)})
+		}
+	}
+	return problems
+}
+
+// Write saves the manifest as indented JSON to filename.
+func (m *Manifest) Write(filename string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0666)
+}
+
+// Read loads a manifest saved by Write.
+func Read(filename string) (*Manifest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
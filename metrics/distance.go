@@ -0,0 +1,139 @@
+package metrics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Distance measures how far a simulated series is from the observed one,
+// over the steps that were observed.
+type Distance func(simulated, observed map[int64]float64) float64
+
+// distances are the distances known to GetDistance
+var distances = map[string]func(differences []float64) float64{
+	"euclidean": func(d []float64) float64 {
+		sum := 0.0
+		for _, x := range d {
+			sum += x * x
+		}
+		return math.Sqrt(sum)
+	},
+	"rmse": func(d []float64) float64 {
+		sum := 0.0
+		for _, x := range d {
+			sum += x * x
+		}
+		return math.Sqrt(sum / float64(len(d)))
+	},
+	"mae": func(d []float64) float64 {
+		sum := 0.0
+		for _, x := range d {
+			sum += math.Abs(x)
+		}
+		return sum / float64(len(d))
+	},
+	"max": func(d []float64) float64 {
+		max := 0.0
+		for _, x := range d {
+			max = math.Max(max, math.Abs(x))
+		}
+		return max
+	},
+}
+
+// DistanceNames returns the names of the known distances, sorted.
+func DistanceNames() []string {
+	var names []string
+	for name := range distances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GetDistance returns the named distance: euclidean, rmse, mae or max.  A
+// simulation missing an observed step is infinitely far away.
+func GetDistance(name string) (Distance, error) {
+	fn, ok := distances[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown distance '%s', expecting one of %s", name, strings.Join(DistanceNames(), ", "))
+	}
+	return func(simulated, observed map[int64]float64) float64 {
+		// in order of the steps, so the sums are the same in every run
+		steps := make([]int64, 0, len(observed))
+		for step := range observed {
+			steps = append(steps, step)
+		}
+		sort.Slice(steps, func(i, j int) bool { return steps[i] < steps[j] })
+		differences := make([]float64, 0, len(observed))
+		for _, step := range steps {
+			s, ok := simulated[step]
+			if !ok {
+				return math.Inf(1)
+			}
+			differences = append(differences, s-observed[step])
+		}
+		if len(differences) == 0 {
+			return math.Inf(1)
+		}
+		return fn(differences)
+	}, nil
+}
+
+// ReadObserved loads an observed series from a CSV file with a header.
+// The file is either a metrics file (step, name, value), from which the
+// rows of the named metric are taken, or has a step column and a column
+// named after the metric or value.
+func ReadObserved(filename, name string) (map[int64]float64, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%s: empty file", filename)
+	}
+	column := func(names ...string) int {
+		for i, header := range rows[0] {
+			for _, n := range names {
+				if strings.TrimSpace(header) == n {
+					return i
+				}
+			}
+		}
+		return -1
+	}
+	stepColumn, nameColumn := column("step"), column("name")
+	valueColumn := column(name, "value")
+	if stepColumn < 0 || valueColumn < 0 {
+		return nil, fmt.Errorf("%s: expecting a step column and a %s or value column", filename, name)
+	}
+	observed := map[int64]float64{}
+	for i, row := range rows[1:] {
+		if nameColumn >= 0 && row[nameColumn] != name {
+			continue
+		}
+		step, err := strconv.ParseInt(strings.TrimSpace(row[stepColumn]), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: invalid step: %w", filename, i+2, err)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(row[valueColumn]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: line %d: invalid value: %w", filename, i+2, err)
+		}
+		observed[step] = value
+	}
+	if len(observed) == 0 {
+		return nil, fmt.Errorf("%s: no observations of %s", filename, name)
+	}
+	return observed, nil
+}
@@ -0,0 +1,54 @@
+package metrics
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDistances(t *testing.T) {
+	observed := map[int64]float64{0: 1, 5: 2, 10: 3, 15: 4}
+	// differences of 3, -4, 0 and 0
+	simulated := map[int64]float64{0: 4, 5: -2, 10: 3, 15: 4, 20: 100}
+	for _, test := range []struct {
+		name string
+		want float64
+	}{
+		{"euclidean", 5},
+		{"rmse", 2.5},
+		{"mae", 1.75},
+		{"max", 4},
+	} {
+		distance, err := GetDistance(test.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := distance(simulated, observed); math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("%s is %g, want %g", test.name, got, test.want)
+		}
+		if got := distance(observed, observed); got != 0 {
+			t.Errorf("%s of the observations from themselves is %g", test.name, got)
+		}
+		if got := distance(map[int64]float64{0: 1, 5: 2}, observed); !math.IsInf(got, 1) {
+			t.Errorf("%s of a simulation missing observed steps is %g, want +Inf", test.name, got)
+		}
+	}
+	if _, err := GetDistance("manhattan"); err == nil {
+		t.Error("an unknown distance was accepted")
+	}
+}
+
+func TestQuantileOfFailedDistances(t *testing.T) {
+	// the failed simulations are infinitely far away
+	sorted := []float64{1, 3, math.Inf(1), math.Inf(1)}
+	for _, test := range []struct {
+		p, want float64
+	}{
+		{0.25, 2.5},
+		{0.5, 3},
+		{1, math.Inf(1)},
+	} {
+		if got := Quantile(sorted, test.p); got != test.want {
+			t.Errorf("quantile %g is %g, want %g", test.p, got, test.want)
+		}
+	}
+}
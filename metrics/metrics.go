@@ -0,0 +1,119 @@
+// Package metrics records the per step values that plugins publish, such
+// as life's count of alive cells, and summarizes them across runs.
+package metrics
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// Recorder collects the values published during a step and writes them to
+// a CSV file with the columns step, name and value when the step ends.
+// Set is safe to call from any hook.
+type Recorder struct {
+	mu     sync.Mutex
+	values map[string]float64
+	file   *os.File
+	out    *csv.Writer
+}
+
+// NewRecorder creates the metrics file filename.
+func NewRecorder(filename string) (*Recorder, error) {
+	file, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{values: map[string]float64{}, file: file, out: csv.NewWriter(file)}
+	r.out.Write([]string{"step", "name", "value"})
+	return r, nil
+}
+
+// Set publishes the value of the named metric for the current step,
+// replacing any value set earlier in the step.
+func (r *Recorder) Set(name string, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.values[name] = value
+}
+
+// EndStep writes the values published during step, sorted by name.
+func (r *Recorder) EndStep(step int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.values))
+	for name := range r.values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		r.out.Write([]string{
+			strconv.FormatInt(step, 10),
+			name,
+			strconv.FormatFloat(r.values[name], 'g', -1, 64),
+		})
+	}
+	r.values = map[string]float64{}
+	r.out.Flush()
+	return r.out.Error()
+}
+
+// Close flushes and closes the file.
+func (r *Recorder) Close() error {
+	r.out.Flush()
+	if err := r.out.Error(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// Series maps a metric name to its value at each step.
+type Series map[string]map[int64]float64
+
+// Read loads a metrics file written by a Recorder.
+func Read(in io.Reader) (Series, error) {
+	reader := csv.NewReader(in)
+	reader.FieldsPerRecord = 3
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	series := Series{}
+	for i, row := range rows {
+		if i == 0 {
+			continue
+		}
+		step, err := strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid step: %w", i+1, err)
+		}
+		value, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid value: %w", i+1, err)
+		}
+		if series[row[1]] == nil {
+			series[row[1]] = map[int64]float64{}
+		}
+		series[row[1]][step] = value
+	}
+	return series, nil
+}
+
+// ReadFile loads the metrics file filename.
+func ReadFile(filename string) (Series, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	series, err := Read(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+	return series, nil
+}
@@ -0,0 +1,39 @@
+package metrics
+
+import "math"
+
+// Sobol estimates the first order and total Sobol indices of each input
+// from the outputs of a Saltelli design: fA and fB for the base matrices A
+// and B, and fAB[i] for A with its i-th column taken from B.  The first
+// order index uses the estimator of Saltelli et al. (2010), the total index
+// that of Jansen (1999).  Both are NaN if the output does not vary.
+func Sobol(fA, fB []float64, fAB [][]float64) (first, total []float64) {
+	n := float64(len(fA))
+	mean := 0.0
+	for i := range fA {
+		mean += fA[i] + fB[i]
+	}
+	mean /= 2 * n
+	variance := 0.0
+	for i := range fA {
+		variance += (fA[i]-mean)*(fA[i]-mean) + (fB[i]-mean)*(fB[i]-mean)
+	}
+	variance /= 2*n - 1
+
+	first = make([]float64, len(fAB))
+	total = make([]float64, len(fAB))
+	for i, fABi := range fAB {
+		var s, st float64
+		for j := range fA {
+			s += fB[j] * (fABi[j] - fA[j])
+			st += (fA[j] - fABi[j]) * (fA[j] - fABi[j])
+		}
+		if variance == 0 {
+			first[i], total[i] = math.NaN(), math.NaN()
+			continue
+		}
+		first[i] = s / n / variance
+		total[i] = st / (2 * n) / variance
+	}
+	return first, total
+}
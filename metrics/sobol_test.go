@@ -0,0 +1,59 @@
+package metrics
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// ishigami is the test function of Ishigami and Homma (1990) with a = 7
+// and b = 0.1, whose inputs are uniform on [-pi, pi]
+func ishigami(x []float64) float64 {
+	return math.Sin(x[0]) + 7*math.Pow(math.Sin(x[1]), 2) + 0.1*math.Pow(x[2], 4)*math.Sin(x[0])
+}
+
+func TestSobolOfIshigami(t *testing.T) {
+	const n, k = 50000, 3
+	rng := rand.New(rand.NewSource(1))
+	sample := func() [][]float64 {
+		rows := make([][]float64, n)
+		for i := range rows {
+			rows[i] = make([]float64, k)
+			for j := range rows[i] {
+				rows[i][j] = (2*rng.Float64() - 1) * math.Pi
+			}
+		}
+		return rows
+	}
+	a, b := sample(), sample()
+	fA, fB := make([]float64, n), make([]float64, n)
+	fAB := make([][]float64, k)
+	for i := range fAB {
+		fAB[i] = make([]float64, n)
+	}
+	for j := 0; j < n; j++ {
+		fA[j], fB[j] = ishigami(a[j]), ishigami(b[j])
+		for i := range fAB {
+			ab := append([]float64(nil), a[j]...)
+			ab[i] = b[j][i]
+			fAB[i][j] = ishigami(ab)
+		}
+	}
+
+	first, total := Sobol(fA, fB, fAB)
+	// the analytic indices, e.g., from Saltelli et al. (2008)
+	for i, want := range []struct{ first, total float64 }{
+		{0.3139, 0.5576},
+		{0.4424, 0.4424},
+		{0, 0.2437},
+	} {
+		if math.Abs(first[i]-want.first) > 0.03 || math.Abs(total[i]-want.total) > 0.03 {
+			t.Errorf("x%d has indices %.4f and %.4f, want %.4f and %.4f", i+1, first[i], total[i], want.first, want.total)
+		}
+	}
+
+	first, total = Sobol([]float64{1, 1}, []float64{1, 1}, [][]float64{{1, 1}})
+	if !math.IsNaN(first[0]) || !math.IsNaN(total[0]) {
+		t.Errorf("a constant output has indices %g and %g, want NaN", first[0], total[0])
+	}
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+)
+
+// Summary is the distribution of a metric at one step across runs.
+type Summary struct {
+	Name      string
+	Step      int64
+	N         int
+	Mean      float64
+	Variance  float64 // sample variance, 0 for a single run
+	Min, Max  float64
+	Quantiles []float64 // at the probabilities given to Summarize
+}
+
+// Summarize computes the distribution of each metric at each step across
+// the runs, with the quantiles at probabilities (e.g., 0.05, 0.5, 0.95).
+// The summaries are sorted by name and step.  A run missing a step (e.g.,
+// one that stopped early) is left out of that step's summary.
+func Summarize(runs []Series, probabilities []float64) []Summary {
+	values := map[string]map[int64][]float64{}
+	for _, series := range runs {
+		for name, steps := range series {
+			if values[name] == nil {
+				values[name] = map[int64][]float64{}
+			}
+			for step, value := range steps {
+				values[name][step] = append(values[name][step], value)
+			}
+		}
+	}
+
+	var summaries []Summary
+	for name, steps := range values {
+		for step, sample := range steps {
+			summaries = append(summaries, summarize(name, step, sample, probabilities))
+		}
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		if summaries[i].Name != summaries[j].Name {
+			return summaries[i].Name < summaries[j].Name
+		}
+		return summaries[i].Step < summaries[j].Step
+	})
+	return summaries
+}
+
+func summarize(name string, step int64, sample []float64, probabilities []float64) Summary {
+	sort.Float64s(sample)
+	s := Summary{Name: name, Step: step, N: len(sample), Min: sample[0], Max: sample[len(sample)-1]}
+	for _, value := range sample {
+		s.Mean += value
+	}
+	s.Mean /= float64(s.N)
+	if s.N > 1 {
+		for _, value := range sample {
+			s.Variance += (value - s.Mean) * (value - s.Mean)
+		}
+		s.Variance /= float64(s.N - 1)
+	}
+	for _, p := range probabilities {
+		s.Quantiles = append(s.Quantiles, Quantile(sample, p))
+	}
+	return s
+}
+
+// Quantile returns the p quantile of the sorted sample, interpolating
+// linearly between the closest ranks unless the upper one is infinite,
+// e.g., the distance of a simulation that failed, when it is the lower one.
+func Quantile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return math.NaN()
+	}
+	h := p * float64(len(sorted)-1)
+	lo := int(math.Floor(h))
+	if lo >= len(sorted)-1 {
+		return sorted[len(sorted)-1]
+	}
+	if lo < 0 {
+		return sorted[0]
+	}
+	if math.IsInf(sorted[lo+1], 1) {
+		return sorted[lo]
+	}
+	return sorted[lo] + (h-float64(lo))*(sorted[lo+1]-sorted[lo])
+}
+
+// Final returns the value of the named metric at the last step it was
+// published.
+func (s Series) Final(name string) (float64, bool) {
+	steps, ok := s[name]
+	if !ok || len(steps) == 0 {
+		return 0, false
+	}
+	last := int64(math.MinInt64)
+	for step := range steps {
+		if step > last {
+			last = step
+		}
+	}
+	return steps[last], true
+}
+
+// Mean returns the mean of the named metric over the steps it was
+// published.
+func (s Series) Mean(name string) (float64, bool) {
+	steps, ok := s[name]
+	if !ok || len(steps) == 0 {
+		return 0, false
+	}
+	sum := 0.0
+	for _, value := range steps {
+		sum += value
+	}
+	return sum / float64(len(steps)), true
+}
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestQuantile(t *testing.T) {
+	sample := []float64{1, 2, 4, 8}
+	for _, test := range []struct {
+		p, want float64
+	}{
+		{0, 1},
+		{1, 8},
+		{-0.5, 1},
+		{1.5, 8},
+		{0.5, 3},
+		{1.0 / 3, 2},
+		{0.25, 1.75},
+		{0.9, 6.8},
+	} {
+		if got := Quantile(sample, test.p); math.Abs(got-test.want) > 1e-12 {
+			t.Errorf("quantile %g is %g, want %g", test.p, got, test.want)
+		}
+	}
+	if got := Quantile([]float64{5}, 0.3); got != 5 {
+		t.Errorf("a quantile of a single value is %g, want 5", got)
+	}
+	if got := Quantile(nil, 0.5); !math.IsNaN(got) {
+		t.Errorf("a quantile of no values is %g, want NaN", got)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	runs := []Series{
+		{"a": {0: 1, 1: 10}, "b": {0: 5}},
+		{"a": {0: 3, 1: 20}},
+		// stopped early
+		{"a": {0: 2}},
+	}
+	summaries := Summarize(runs, []float64{0, 0.5, 1})
+	for i, want := range []Summary{
+		{Name: "a", Step: 0, N: 3, Mean: 2, Variance: 1, Min: 1, Max: 3, Quantiles: []float64{1, 2, 3}},
+		{Name: "a", Step: 1, N: 2, Mean: 15, Variance: 50, Min: 10, Max: 20, Quantiles: []float64{10, 15, 20}},
+		{Name: "b", Step: 0, N: 1, Mean: 5, Variance: 0, Min: 5, Max: 5, Quantiles: []float64{5, 5, 5}},
+	} {
+		if i >= len(summaries) {
+			t.Fatalf("%d summaries, want 3", len(summaries))
+		}
+		if fmt.Sprint(summaries[i]) != fmt.Sprint(want) {
+			t.Errorf("summary %d is %+v, want %+v", i, summaries[i], want)
+		}
+	}
+	if len(summaries) != 3 {
+		t.Errorf("%d summaries, want 3", len(summaries))
+	}
+}
@@ -0,0 +1,297 @@
+package plugins
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// ConfigKey describes one configuration value as declared by the fields of
+// a registered config struct.  The field tags are:
+//
+//	mapstructure:"x_size"   the key within the section (required)
+//	desc:"..."              a description of the value
+//	min:"3" max:"1024"      the allowed range of a numeric value
+//	above:"0"               a bound a numeric value must be greater than
+//	oneof:"text,json"       the allowed values of a string
+//	match:"^[0-9]+$"        a regular expression a string must match
+//	file:"input"            the value names a file or directory that must exist
+//	file:"output"           the value names a file the plugin writes
+type ConfigKey struct {
+	Key         string       // full key, e.g. life.x_size
+	Kind        reflect.Kind // kind of the field
+	Type        string       // type name of the field, e.g. int or time.Duration
+	Default     any          // value of the field in the registered struct
+	Description string
+	Min, Max    *float64
+	Above       *float64 // exclusive lower bound
+	OneOf       []string
+	Match       *regexp.Regexp
+	InputFile   bool
+	OutputFile  bool
+}
+
+// ConfigSchema is the set of keys registered by a plugin (or the engine for
+// the top level section, named "").
+type ConfigSchema struct {
+	Section string
+	Keys    []ConfigKey
+}
+
+var configSchemas = map[string]*ConfigSchema{}
+
+// RegisterConfig declares the typed configuration of a section, normally
+// the plugin's name, from a struct value whose fields hold the defaults.
+// The defaults are set in viper so they can be overridden by the config
+// file and environment.  Register functions call this so the schema is
+// known before the configuration is read.
+func RegisterConfig(section string, defaults any) {
+	value := reflect.ValueOf(defaults)
+	if value.Kind() == reflect.Pointer {
+		value = value.Elem()
+	}
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Errorf("config for '%s' must be a struct, not %s", section, value.Kind()))
+	}
+	schema := &ConfigSchema{Section: section}
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name := field.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+		key := ConfigKey{
+			Key:         name,
+			Kind:        field.Type.Kind(),
+			Type:        field.Type.String(),
+			Default:     value.Field(i).Interface(),
+			Description: field.Tag.Get("desc"),
+			InputFile:   field.Tag.Get("file") == "input",
+			OutputFile:  field.Tag.Get("file") == "output",
+		}
+		if section != "" {
+			key.Key = section + "." + name
+		}
+		for tag, bound := range map[string]**float64{"min": &key.Min, "max": &key.Max, "above": &key.Above} {
+			if text := field.Tag.Get(tag); text != "" {
+				limit, err := strconv.ParseFloat(text, 64)
+				if err != nil {
+					panic(fmt.Errorf("invalid %s tag on %s: %w", tag, key.Key, err))
+				}
+				*bound = &limit
+			}
+		}
+		if text := field.Tag.Get("oneof"); text != "" {
+			key.OneOf = strings.Split(text, ",")
+		}
+		if text := field.Tag.Get("match"); text != "" {
+			key.Match = regexp.MustCompile(text)
+		}
+		viper.SetDefault(key.Key, key.Default)
+		schema.Keys = append(schema.Keys, key)
+	}
+	configSchemas[section] = schema
+}
+
+// GetConfigSchema returns the schema registered for a section, or nil.
+func GetConfigSchema(section string) *ConfigSchema {
+	return configSchemas[section]
+}
+
+// InputFileKeys returns the keys of the registered values of the engine and
+// the loaded plugin instances that name input files, sorted.
+func InputFileKeys() []string {
+	return fileKeys(func(key ConfigKey) bool { return key.InputFile })
+}
+
+// OutputFileKeys returns the keys of the registered values of the loaded
+// plugin instances that name files they write, sorted.
+func OutputFileKeys() []string {
+	return fileKeys(func(key ConfigKey) bool { return key.OutputFile })
+}
+
+func fileKeys(match func(ConfigKey) bool) []string {
+	var keys []string
+	sections := loadedSections()
+	for _, schema := range configSchemas {
+		if !sections[schema.Section] {
+			continue
+		}
+		for _, key := range schema.Keys {
+			if match(key) {
+				keys = append(keys, key.Key)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LoadConfig decodes the section of the configuration into the struct
+// pointed to by out, which is normally the struct passed to RegisterConfig.
+// Each registered key is looked up on its own since viper.UnmarshalKey on a
+// section misses environment variables and is shadowed by a single
+// overridden key within the section.
+func LoadConfig(section string, out any) error {
+	schema, ok := configSchemas[section]
+	if !ok {
+		return fmt.Errorf("no configuration registered for '%s'", section)
+	}
+	settings := map[string]any{}
+	for _, key := range schema.Keys {
+		name := key.Key
+		if section != "" {
+			name = strings.TrimPrefix(name, section+".")
+		}
+		settings[name] = viper.Get(key.Key)
+	}
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       mapstructure.StringToTimeDurationHookFunc(),
+		WeaklyTypedInput: true,
+		Result:           out,
+	})
+	if err != nil {
+		return err
+	}
+	return decoder.Decode(settings)
+}
+
+// ConfigProblem is something wrong with a configuration value.  Unknown
+// keys are not fatal since they may be meant for a plugin that is not
+// compiled in, but they are often typos.
+type ConfigProblem struct {
+	Key     string
+	Problem string
+	Fatal   bool
+}
+
+func (p ConfigProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Key, p.Problem)
+}
+
+// ValidateConfig checks the current configuration against the registered
+// schemas and returns the problems found, sorted by key.
+func ValidateConfig() []ConfigProblem {
+	var problems []ConfigProblem
+	known := map[string]ConfigKey{}
+	for _, schema := range configSchemas {
+		for _, key := range schema.Keys {
+			known[key.Key] = key
+		}
+	}
+
+	for _, name := range viper.AllKeys() {
+		if _, ok := known[name]; ok {
+			continue
+		}
+		if inMap(known, name) || resolvedKey(name) {
+			continue
+		}
+		problems = append(problems, ConfigProblem{Key: name, Problem: "unknown key"})
+	}
+
+	for _, key := range known {
+		if problem := checkValue(key, viper.Get(key.Key)); problem != "" {
+			problems = append(problems, ConfigProblem{Key: key.Key, Problem: problem, Fatal: true})
+		}
+	}
+
+	sort.Slice(problems, func(i, j int) bool { return problems[i].Key < problems[j].Key })
+	return problems
+}
+
+// resolvedKeys are the keys of a configuration file that are resolved when it
+// is read, extends and profiles, rather than being part of the configuration
+var resolvedKeys = map[string]bool{"extends": true, "profiles": true}
+
+// resolvedKey reports if name is one of the resolvedKeys or an entry of one
+func resolvedKey(name string) bool {
+	section, _, _ := strings.Cut(name, ".")
+	return resolvedKeys[section]
+}
+
+// inMap reports if name is an entry of a map value, since the entries of
+// a map are not declared individually
+func inMap(known map[string]ConfigKey, name string) bool {
+	for i := strings.LastIndexByte(name, '.'); i > 0; i = strings.LastIndexByte(name[:i], '.') {
+		if key, ok := known[name[:i]]; ok && key.Kind == reflect.Map {
+			return true
+		}
+	}
+	return false
+}
+
+// checkValue returns a description of what is wrong with value for key, if anything
+func checkValue(key ConfigKey, value any) string {
+	var number float64
+	var err error
+	switch {
+	case key.Type == "time.Duration":
+		var d time.Duration
+		d, err = cast.ToDurationE(value)
+		number = float64(d)
+	case key.Kind >= reflect.Int && key.Kind <= reflect.Uint64:
+		number, err = cast.ToFloat64E(value)
+		if err == nil && number != math.Trunc(number) {
+			err = fmt.Errorf("not an integer")
+		}
+	case key.Kind == reflect.Float32 || key.Kind == reflect.Float64:
+		number, err = cast.ToFloat64E(value)
+	case key.Kind == reflect.Bool:
+		_, err = cast.ToBoolE(value)
+	case key.Kind == reflect.String:
+		var text string
+		text, err = cast.ToStringE(value)
+		if err == nil && len(key.OneOf) > 0 && !contains(key.OneOf, text) {
+			return fmt.Sprintf("'%s' is not one of %s", text, strings.Join(key.OneOf, ", "))
+		}
+		if err == nil && key.Match != nil && !key.Match.MatchString(text) {
+			return fmt.Sprintf("'%s' does not match %s", text, key.Match)
+		}
+		if err == nil && key.InputFile && text != "" {
+			if _, statErr := os.Stat(text); statErr != nil {
+				return fmt.Sprintf("input file '%s' is not readable: %v", text, statErr)
+			}
+		}
+	case key.Kind == reflect.Map:
+		if reflect.ValueOf(value).Kind() != reflect.Map {
+			_, err = cast.ToStringMapE(value)
+		}
+	case key.Type == "[]string":
+		_, err = cast.ToStringSliceE(value)
+	case key.Kind == reflect.Slice:
+		_, err = cast.ToSliceE(value)
+	}
+	if err != nil {
+		return fmt.Sprintf("value %v is not a valid %s", value, key.Type)
+	}
+	if key.Min != nil && number < *key.Min {
+		return fmt.Sprintf("value %v is less than the minimum of %v", value, *key.Min)
+	}
+	if key.Above != nil && number <= *key.Above {
+		return fmt.Sprintf("value %v must be greater than %v", value, *key.Above)
+	}
+	if key.Max != nil && number > *key.Max {
+		return fmt.Sprintf("value %v is greater than the maximum of %v", value, *key.Max)
+	}
+	return ""
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
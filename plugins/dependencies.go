@@ -0,0 +1,50 @@
+package plugins
+
+import (
+	"fmt"
+	"strings"
+)
+
+var dependencies = map[string][]string{}
+
+// RegisterDependencies declares the plugins, by name, that a plugin needs to
+// be compiled in, e.g., because it reads the data another one publishes.
+// Register functions call this.  Names are not case sensitive.
+func RegisterDependencies(plugin string, needs ...string) {
+	plugin = strings.ToLower(plugin)
+	dependencies[plugin] = append(dependencies[plugin], needs...)
+}
+
+// GetDependencies returns the plugins that a plugin, or the plugin an
+// instance is of, declared it needs.
+func GetDependencies(plugin string) []string {
+	return dependencies[strings.ToLower(BaseName(plugin))]
+}
+
+// FindPlugin returns the compiled-in plugin with the name, which is not
+// case sensitive.
+func FindPlugin(name string) (Plugin, bool) {
+	for _, plugin := range LoadedPlugins {
+		if strings.EqualFold(plugin.Name(), name) {
+			return plugin, true
+		}
+	}
+	return Plugin{}, false
+}
+
+// checkDependencies returns an error naming the first dependency without
+// an instance among the plugins
+func checkDependencies(plugins []Plugin) error {
+	for _, plugin := range plugins {
+		for _, need := range GetDependencies(plugin.Name()) {
+			found := false
+			for _, other := range plugins {
+				found = found || strings.EqualFold(BaseName(other.Name()), need)
+			}
+			if !found {
+				return fmt.Errorf("plugin '%s' needs the plugin '%s', which is not loaded", plugin.Name(), need)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,148 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Factory creates an instance of a plugin named instance, which is the
+// plugin's name for the default instance or the name followed by # and a
+// tag, e.g., Life#a.  The instance uses the name for Name(), the plugin
+// attribute of its logs, its metrics and, in lower case, its configuration
+// section (see Section).
+type Factory func(instance string) Plugin
+
+// the factories of the plugins that support more than one instance, by
+// lower case plugin name
+var factories = map[string]Factory{}
+
+// the plugins given to Register, the default instances
+var registered []Plugin
+
+// RegisterFactory declares a plugin that can be instantiated more than
+// once.  The default instance, named name, is registered right away so the
+// plugin runs as before unless the plugins configuration value names other
+// instances.
+func RegisterFactory(name string, factory Factory) {
+	factories[strings.ToLower(name)] = factory
+	Register(factory(name))
+}
+
+// Section returns the configuration section of a plugin instance: its name
+// in lower case, e.g., life or life#a.
+func Section(instance string) string {
+	return strings.ToLower(instance)
+}
+
+// BaseName returns the name of the plugin an instance is of, e.g., Life for
+// Life#a.
+func BaseName(instance string) string {
+	name, _, _ := strings.Cut(instance, "#")
+	return name
+}
+
+// Instantiate replaces LoadedPlugins with the named instances, e.g.,
+// life#a and life#b, in the given order.  A plugin's default instance is
+// named by its name alone.  Only plugins registered with RegisterFactory can
+// have tagged instances.  Each tagged instance gets a copy of the plugin's
+// configuration section whose defaults are the plugin section's values,
+// with output file names tagged (out.rle becomes out.a.rle) so instances do
+// not overwrite each other's results.  With no names the default instances
+// of all of the plugins are kept.
+func Instantiate(names []string) error {
+	if len(names) == 0 {
+		return nil
+	}
+	var instances []Plugin
+	seen := map[string]bool{}
+	for _, name := range names {
+		base, tag, tagged := strings.Cut(strings.TrimSpace(name), "#")
+		var plugin Plugin
+		found := false
+		for _, p := range registered {
+			if strings.EqualFold(p.Name(), base) {
+				plugin, found = p, true
+			}
+		}
+		if !found {
+			return fmt.Errorf("plugins: no plugin named '%s' is compiled in", base)
+		}
+		if tagged && (tag == "" || strings.ContainsAny(tag, "#. ")) {
+			return fmt.Errorf("plugins: invalid instance name '%s', expecting e.g. %s#a", name, base)
+		}
+		instance := plugin.Name()
+		if tagged {
+			instance += "#" + strings.ToLower(tag)
+		}
+		if seen[Section(instance)] {
+			return fmt.Errorf("plugins: instance '%s' is listed more than once", instance)
+		}
+		seen[Section(instance)] = true
+		if !tagged {
+			instances = append(instances, plugin)
+			continue
+		}
+		factory, ok := factories[Section(plugin.Name())]
+		if !ok {
+			return fmt.Errorf("plugins: the plugin '%s' does not support more than one instance", plugin.Name())
+		}
+		registerInstanceConfig(Section(plugin.Name()), Section(instance), strings.ToLower(tag))
+		instances = append(instances, factory(instance))
+	}
+	LoadedPlugins = instances
+	return nil
+}
+
+// registerInstanceConfig declares the configuration section of an instance
+// as a copy of its plugin's section, defaulting to the plugin section's
+// current values
+func registerInstanceConfig(section, instance, tag string) {
+	schema, ok := configSchemas[section]
+	if !ok {
+		return
+	}
+	copied := &ConfigSchema{Section: instance}
+	for _, key := range schema.Keys {
+		base := key.Key
+		key.Key = instance + strings.TrimPrefix(base, section)
+		key.Default = viper.Get(base)
+		if text, ok := key.Default.(string); ok && key.OutputFile && text != "" {
+			extension := filepath.Ext(text)
+			key.Default = strings.TrimSuffix(text, extension) + "." + tag + extension
+		}
+		viper.SetDefault(key.Key, key.Default)
+		copied.Keys = append(copied.Keys, key)
+	}
+	configSchemas[instance] = copied
+}
+
+// instanceContext gives a tagged instance its own random seed, derived from
+// the engine's seed and the instance name, so that instances with the same
+// configuration do not make the same random choices
+func instanceContext(ctx context.Context, instance string) context.Context {
+	seed, ok := ctx.Value("random_seed").(int64)
+	if !ok || BaseName(instance) == instance {
+		return ctx
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(Section(instance)))
+	z := uint64(seed) ^ hash.Sum64()
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return context.WithValue(ctx, "random_seed", int64((z^(z>>31))>>1))
+}
+
+// loadedSections returns the configuration sections of the loaded plugin
+// instances and of the engine
+func loadedSections() map[string]bool {
+	sections := map[string]bool{"": true}
+	for _, plugin := range LoadedPlugins {
+		sections[Section(plugin.Name())] = true
+	}
+	return sections
+}
@@ -2,8 +2,10 @@ package plugins
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"sync"
 )
 
 type Hook struct {
@@ -20,6 +22,7 @@ type PluginDescription func() string
 type PluginGetHooks func() []Hook
 type PluginPreRun func(context.Context) error
 type PluginPostRun func(context.Context) error
+type PluginShutdown func(context.Context) error
 
 type Plugin struct {
 	Init        PluginInit
@@ -29,29 +32,147 @@ type Plugin struct {
 	GetHooks    PluginGetHooks
 	PreRun      PluginPreRun
 	PostRun     PluginPostRun
+	// Shutdown, if not nil, releases the state of the plugin after a run so
+	// that Init starts afresh for the next run in the same process.
+	Shutdown PluginShutdown
 }
 
+// Display is on the context as "display" when the engine renders a terminal
+// dashboard.  Plugins draw a text view of their state into a named panel
+// with it instead of writing to stdout, which would corrupt the screen.
+// Draw may be called from any hook; the engine renders from its own goroutine.
+type Display interface {
+	Draw(panel string, lines []string)
+}
+
+// Metrics is on the context as "metrics" when the engine records the per
+// step values that plugins publish, e.g., for aggregating replicate runs.
+// Names should be prefixed with the plugin's name, e.g., life.alive_cells.
+// Set may be called from any hook; the values are written at the end of
+// each step.
+type Metrics interface {
+	Set(name string, value float64)
+}
+
+// LoadedPlugins are the compiled-in plugins, or the instances named by the
+// plugins configuration value (see Instantiate).  An Engine runs its own
+// instances of them.
 var LoadedPlugins []Plugin
 
-func LoadPlugins(ctx context.Context) error {
-	log := ctx.Value("log").(*slog.Logger)
+// Register adds a plugin to LoadedPlugins unless one with the same name is
+// already there, so a plugin's Register function may be called again.
+func Register(plugin Plugin) {
+	if _, ok := FindPlugin(plugin.Name()); ok {
+		return
+	}
+	LoadedPlugins = append(LoadedPlugins, plugin)
+	registered = append(registered, plugin)
+}
+
+// Engine holds the plugin instances of one simulation and which of them
+// are initialized, so that simulations in the same process, one after the
+// other or at the same time, do not share the state of their plugins.
+type Engine struct {
+	// Plugins are the instances, in the order they run
+	Plugins []Plugin
+
+	mu          sync.Mutex
+	initialized map[string]bool
+}
 
+// NewEngine returns an engine with new instances of the LoadedPlugins made
+// by their factories (see RegisterFactory); a plugin registered without a
+// factory is shared by the engines.
+func NewEngine() *Engine {
+	e := &Engine{initialized: map[string]bool{}}
 	for _, plugin := range LoadedPlugins {
-		// call init
-		err := plugin.Init(ctx)
-		if err != nil {
-			log.Error("plugin initialization failed")
+		if factory, ok := factories[Section(BaseName(plugin.Name()))]; ok {
+			plugin = factory(plugin.Name())
+		}
+		e.Plugins = append(e.Plugins, plugin)
+	}
+	return e
+}
+
+// LoadPlugins calls Init for each of the engine's plugins that is not
+// already initialized, so calling it again does not reinitialize them.
+// Call ShutdownPlugins at the end of a run to start the next one afresh.
+func LoadPlugins(ctx context.Context, e *Engine) error {
+	log := ctx.Value("log").(*slog.Logger)
+
+	if err := checkDependencies(e.Plugins); err != nil {
+		log.Error("a plugin dependency is missing")
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, plugin := range e.Plugins {
+		if err := e.loadPlugin(ctx, plugin); err != nil {
 			return err
 		}
-		// call the data functions to print some information and verify
-		name := plugin.Name()
-		description := plugin.Description()
-		hooks := plugin.GetHooks()
-		major, minor, patch := plugin.Version()
-		log.Info(fmt.Sprintf("plugin: %s v%d.%d.%d - %s - %d hooks",
-			name, major, minor, patch, description, len(hooks),
-		))
 	}
 
 	return nil
 }
+
+// LoadPlugin calls Init for one of the engine's plugins if it is not
+// already initialized, without checking its dependencies, e.g., to describe
+// the plugins that can be initialized when others cannot.
+func LoadPlugin(ctx context.Context, e *Engine, plugin Plugin) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.loadPlugin(ctx, plugin)
+}
+
+// loadPlugin must be called with e.mu held
+func (e *Engine) loadPlugin(ctx context.Context, plugin Plugin) error {
+	log := ctx.Value("log").(*slog.Logger)
+	name := plugin.Name()
+	if e.initialized[name] {
+		log.Debug(fmt.Sprintf("plugin: %s is already initialized", name))
+		return nil
+	}
+	// call init
+	err := plugin.Init(instanceContext(ctx, name))
+	if err != nil {
+		log.Error("plugin initialization failed")
+		return err
+	}
+	e.initialized[name] = true
+	// call the data functions to print some information and verify
+	description := plugin.Description()
+	hooks := plugin.GetHooks()
+	major, minor, patch := plugin.Version()
+	log.Info(fmt.Sprintf("plugin: %s v%d.%d.%d - %s - %d hooks",
+		name, major, minor, patch, description, len(hooks),
+	))
+	return nil
+}
+
+// ShutdownPlugins calls Shutdown for each initialized plugin of the engine,
+// in the reverse of the order they were initialized, so that LoadPlugins
+// initializes them again.  All of the plugins are shut down even if some
+// fail.
+func ShutdownPlugins(ctx context.Context, e *Engine) error {
+	log := ctx.Value("log").(*slog.Logger)
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	var errs []error
+	for i := len(e.Plugins) - 1; i >= 0; i-- {
+		plugin := e.Plugins[i]
+		name := plugin.Name()
+		if !e.initialized[name] {
+			continue
+		}
+		delete(e.initialized, name)
+		if plugin.Shutdown == nil {
+			continue
+		}
+		if err := plugin.Shutdown(ctx); err != nil {
+			log.Error(fmt.Sprintf("plugin: %s shutdown failed", name))
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
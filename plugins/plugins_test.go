@@ -0,0 +1,197 @@
+package plugins
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// counter is a plugin that counts the calls to its lifecycle functions
+type counter struct {
+	name             string
+	inits, shutdowns int
+	steps            int
+}
+
+func (c *counter) plugin() Plugin {
+	return Plugin{
+		Init:        func(context.Context) error { c.inits += 1; return nil },
+		Name:        func() string { return c.name },
+		Version:     func() (int, int, int) { return 1, 0, 0 },
+		Description: func() string { return "counts calls" },
+		GetHooks: func() []Hook {
+			return []Hook{{Core: func(context.Context) error { c.steps += 1; return nil }}}
+		},
+		PreRun:   func(context.Context) error { return nil },
+		PostRun:  func(context.Context) error { return nil },
+		Shutdown: func(context.Context) error { c.shutdowns += 1; return nil },
+	}
+}
+
+func withPlugins(t *testing.T, plugins ...Plugin) context.Context {
+	saved, savedRegistered := LoadedPlugins, registered
+	LoadedPlugins, registered = nil, nil
+	t.Cleanup(func() { LoadedPlugins, registered = saved, savedRegistered })
+	for _, plugin := range plugins {
+		Register(plugin)
+	}
+	return context.WithValue(context.Background(), "log", slog.New(slog.NewTextHandler(io.Discard, nil)))
+}
+
+func TestRegisterIgnoresDuplicates(t *testing.T) {
+	c := &counter{name: "counter"}
+	withPlugins(t, c.plugin(), c.plugin())
+	if len(LoadedPlugins) != 1 {
+		t.Errorf("registered %d plugins, want 1", len(LoadedPlugins))
+	}
+}
+
+func TestLoadPluginsIsIdempotent(t *testing.T) {
+	a, b := &counter{name: "a"}, &counter{name: "b"}
+	ctx := withPlugins(t, a.plugin(), b.plugin())
+	engine := NewEngine()
+	for i := 0; i < 2; i++ {
+		if err := LoadPlugins(ctx, engine); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.inits != 1 || b.inits != 1 {
+		t.Errorf("Init called %d and %d times, want once each", a.inits, b.inits)
+	}
+
+	if err := ShutdownPlugins(ctx, engine); err != nil {
+		t.Fatal(err)
+	}
+	if err := ShutdownPlugins(ctx, engine); err != nil {
+		t.Fatal(err)
+	}
+	if a.shutdowns != 1 || b.shutdowns != 1 {
+		t.Errorf("Shutdown called %d and %d times, want once each", a.shutdowns, b.shutdowns)
+	}
+
+	// a new run initializes them again
+	if err := LoadPlugins(ctx, engine); err != nil {
+		t.Fatal(err)
+	}
+	if a.inits != 2 || b.inits != 2 {
+		t.Errorf("Init called %d and %d times after a shutdown, want twice each", a.inits, b.inits)
+	}
+	ShutdownPlugins(ctx, engine)
+}
+
+func TestLoadPluginDoesNotStopAtAFailure(t *testing.T) {
+	a, b := &counter{name: "a"}, &counter{name: "b"}
+	failing := a.plugin()
+	failing.Init = func(context.Context) error { return fmt.Errorf("no input") }
+	ctx := withPlugins(t, failing, b.plugin())
+	engine := NewEngine()
+	if err := LoadPlugin(ctx, engine, engine.Plugins[0]); err == nil {
+		t.Error("the failing plugin was initialized")
+	}
+	if err := LoadPlugin(ctx, engine, engine.Plugins[1]); err != nil {
+		t.Fatal(err)
+	}
+	if err := ShutdownPlugins(ctx, engine); err != nil {
+		t.Fatal(err)
+	}
+	if b.inits != 1 || a.shutdowns != 0 || b.shutdowns != 1 {
+		t.Errorf("b was initialized %d times, a shut down %d and b %d times, want 1, 0 and 1", b.inits, a.shutdowns, b.shutdowns)
+	}
+}
+
+func TestEnginesRunAtOnce(t *testing.T) {
+	ctx := withPlugins(t)
+	var created []*counter
+	RegisterFactory("Multi", func(instance string) Plugin {
+		c := &counter{name: instance}
+		created = append(created, c)
+		return c.plugin()
+	})
+	t.Cleanup(func() { delete(factories, "multi") })
+
+	engines := []*Engine{NewEngine(), NewEngine()}
+	errs := make([]error, len(engines))
+	wg := new(sync.WaitGroup)
+	for i, engine := range engines {
+		wg.Add(1)
+		go func(i int, engine *Engine) {
+			defer wg.Done()
+			if errs[i] = LoadPlugins(ctx, engine); errs[i] != nil {
+				return
+			}
+			// the first engine shuts down while the second is still running
+			for step := 0; step < (i+1)*100; step++ {
+				for _, hook := range engine.Plugins[0].GetHooks() {
+					hook.Core(ctx)
+				}
+			}
+			errs[i] = ShutdownPlugins(ctx, engine)
+		}(i, engine)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("engine %d: %v", i, err)
+		}
+	}
+
+	// the registered instance is not run by either engine
+	if len(created) != 3 || created[0].inits != 0 {
+		t.Fatalf("%d instances were created and the registered one initialized %d times", len(created), created[0].inits)
+	}
+	for i, c := range created[1:] {
+		if c.inits != 1 || c.shutdowns != 1 || c.steps != (i+1)*100 {
+			t.Errorf("engine %d: initialized %d times, shut down %d times and stepped %d times, want 1, 1 and %d",
+				i, c.inits, c.shutdowns, c.steps, (i+1)*100)
+		}
+	}
+}
+
+func TestMissingDependency(t *testing.T) {
+	c := &counter{name: "needy"}
+	ctx := withPlugins(t, c.plugin())
+	RegisterDependencies("needy", "absent")
+	t.Cleanup(func() { delete(dependencies, "needy") })
+	if err := LoadPlugins(ctx, NewEngine()); err == nil {
+		t.Error("loaded a plugin with a missing dependency")
+	}
+}
+
+func TestInstantiate(t *testing.T) {
+	withPlugins(t, (&counter{name: "plain"}).plugin())
+	RegisterFactory("Multi", func(instance string) Plugin {
+		return (&counter{name: instance}).plugin()
+	})
+	t.Cleanup(func() { delete(factories, "multi") })
+
+	if err := Instantiate([]string{"multi#a", "plain", "Multi#B"}); err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, plugin := range LoadedPlugins {
+		names = append(names, plugin.Name())
+	}
+	if fmt.Sprint(names) != "[Multi#a plain Multi#b]" {
+		t.Errorf("instantiated %v", names)
+	}
+
+	for _, bad := range [][]string{{"plain#a"}, {"absent"}, {"multi#a", "multi#A"}, {"multi#"}} {
+		if err := Instantiate(bad); err == nil {
+			t.Errorf("instantiated %v", bad)
+		}
+	}
+}
+
+func TestAboveIsExclusive(t *testing.T) {
+	above := 0.0
+	key := ConfigKey{Key: "step_duration", Kind: reflect.Float64, Type: "float64", Above: &above}
+	for value, ok := range map[float64]bool{-1: false, 0: false, 0.5: true} {
+		if problem := checkValue(key, value); (problem == "") != ok {
+			t.Errorf("checking %v gave '%s'", value, problem)
+		}
+	}
+}
@@ -0,0 +1,305 @@
+// Package tui renders a terminal dashboard for a running engine: progress,
+// step rate, ETA, per-thread utilization, the panels that plugins draw into
+// and a scrolling pane with the tail of the console log.  Everything is
+// drawn by a single goroutine; the engine and plugins only update state.
+package tui
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/width"
+)
+
+// the terminal size used when it cannot be determined
+const (
+	defaultColumns = 100
+	defaultRows    = 40
+)
+
+// the number of console log lines kept for the log pane
+const logHistory = 500
+
+// Dashboard holds the state shown on the screen.  All of its methods are
+// safe to call from any goroutine.
+type Dashboard struct {
+	out        io.Writer
+	title      string
+	totalSteps int64
+	interval   time.Duration
+
+	start time.Time
+	steps atomic.Int64   // completed steps
+	busy  []atomic.Int64 // nanoseconds each thread spent in hooks
+
+	mu     sync.Mutex
+	panels map[string][]string
+	order  []string // panel names in the order they were first drawn
+	log    []string // tail of the console log
+	// partial line left over from the last write to the log pane
+	partial []byte
+
+	done    chan struct{}
+	stopped chan struct{}
+	stop    sync.Once
+}
+
+// New creates a dashboard for a run of totalSteps steps over threads
+// threads that draws to out every interval once started.
+func New(out io.Writer, title string, totalSteps int64, threads int, interval time.Duration) *Dashboard {
+	return &Dashboard{
+		out:        out,
+		title:      title,
+		totalSteps: totalSteps,
+		interval:   interval,
+		busy:       make([]atomic.Int64, threads),
+		panels:     map[string][]string{},
+		done:       make(chan struct{}),
+		stopped:    make(chan struct{}),
+	}
+}
+
+// Start begins rendering in the background.
+func (d *Dashboard) Start() {
+	d.start = time.Now()
+	// hide the cursor and clear the screen
+	fmt.Fprint(d.out, "\033[?25l\033[H\033[2J")
+	go func() {
+		defer close(d.stopped)
+		ticker := time.NewTicker(d.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				d.render()
+			case <-d.done:
+				d.render()
+				// show the cursor again and leave the final frame on screen
+				fmt.Fprint(d.out, "\033[?25h")
+				return
+			}
+		}
+	}()
+}
+
+// Stop draws the final frame, waits for the renderer to finish and gives
+// the terminal back.  It may be called more than once, e.g., when a thread
+// panics before the run ends, and does nothing after the first call.
+func (d *Dashboard) Stop() {
+	d.stop.Do(func() {
+		close(d.done)
+		<-d.stopped
+	})
+}
+
+// StepDone records that step (counting from 0) has finished.
+func (d *Dashboard) StepDone(step int64) {
+	d.steps.Store(step + 1)
+}
+
+// ThreadBusy adds time that a thread spent working rather than waiting.
+func (d *Dashboard) ThreadBusy(thread int, busy time.Duration) {
+	d.busy[thread].Add(int64(busy))
+}
+
+// Draw replaces the contents of a plugin's panel; see plugins.Display.
+func (d *Dashboard) Draw(panel string, lines []string) {
+	copied := make([]string, len(lines))
+	copy(copied, lines)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.panels[panel]; !ok {
+		d.order = append(d.order, panel)
+	}
+	d.panels[panel] = copied
+}
+
+// Write adds console log output to the log pane; see logger.SetConsoleWriter.
+func (d *Dashboard) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	data := append(d.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		d.log = append(d.log, string(data[:i]))
+		data = data[i+1:]
+	}
+	d.partial = append([]byte(nil), data...)
+	if len(d.log) > logHistory {
+		d.log = append([]string(nil), d.log[len(d.log)-logHistory:]...)
+	}
+	return len(p), nil
+}
+
+// bar draws a progress bar of width characters filled to fraction
+func bar(fraction float64, width int) string {
+	if fraction < 0 {
+		fraction = 0
+	} else if fraction > 1 {
+		fraction = 1
+	}
+	filled := int(fraction*float64(width) + 0.5)
+	return "[" + strings.Repeat("#", filled) + strings.Repeat(".", width-filled) + "]"
+}
+
+// rule draws a section heading across the screen
+func rule(title string, width int) string {
+	heading := "-- " + title + " "
+	if len(heading) >= width {
+		return heading
+	}
+	return heading + strings.Repeat("-", width-len(heading))
+}
+
+// truncate cuts line to at most columns of display width.  Escape
+// sequences take no room and are kept, so colors set before the cut are
+// still reset after it; wide characters take two columns and combining
+// marks none.
+func truncate(line string, columns int) string {
+	var out strings.Builder
+	used := 0
+	for i := 0; i < len(line); {
+		if line[i] == '\033' {
+			// a control sequence runs to its final byte, in @ to ~
+			end := i + 1
+			if end < len(line) && line[end] == '[' {
+				end += 1
+				for end < len(line) && (line[end] < 0x40 || line[end] > 0x7e) {
+					end += 1
+				}
+			}
+			if end < len(line) {
+				end += 1
+			}
+			out.WriteString(line[i:end])
+			i = end
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(line[i:])
+		cells := 1
+		switch {
+		case unicode.Is(unicode.Mn, r) || unicode.IsControl(r):
+			cells = 0
+		case width.LookupRune(r).Kind() == width.EastAsianWide || width.LookupRune(r).Kind() == width.EastAsianFullwidth:
+			cells = 2
+		}
+		if used+cells <= columns {
+			out.WriteString(line[i : i+size])
+			used += cells
+		}
+		i += size
+	}
+	return out.String()
+}
+
+func (d *Dashboard) frame(columns, rows int) []string {
+	var lines []string
+	elapsed := time.Since(d.start)
+	steps := d.steps.Load()
+
+	// progress, rate and ETA
+	fraction := 0.0
+	if d.totalSteps > 0 {
+		fraction = float64(steps) / float64(d.totalSteps)
+	}
+	status := fmt.Sprintf(" %d/%d steps %5.1f%%", steps, d.totalSteps, 100*fraction)
+	width := columns - len(d.title) - len(status) - 3
+	if width < 10 {
+		width = 10
+	}
+	lines = append(lines, fmt.Sprintf("%s %s%s", d.title, bar(fraction, width), status))
+	rate := 0.0
+	if elapsed > 0 {
+		rate = float64(steps) / elapsed.Seconds()
+	}
+	eta := "unknown"
+	if rate > 0 {
+		remaining := time.Duration(float64(d.totalSteps-steps) / rate * float64(time.Second))
+		eta = remaining.Round(time.Millisecond).String()
+	}
+	lines = append(lines, fmt.Sprintf("rate %.1f steps/s  elapsed %v  ETA %s",
+		rate, elapsed.Round(time.Millisecond), eta))
+
+	// per-thread utilization, as many per line as fit
+	var cells []string
+	for i := range d.busy {
+		utilization := 0.0
+		if elapsed > 0 {
+			utilization = float64(d.busy[i].Load()) / float64(elapsed)
+		}
+		cells = append(cells, fmt.Sprintf("%3d %s %3.0f%%", i, bar(utilization, 10), 100*utilization))
+	}
+	lines = append(lines, rule("threads", columns))
+	perLine := columns / 22
+	if perLine < 1 {
+		perLine = 1
+	}
+	for i := 0; i < len(cells); i += perLine {
+		end := i + perLine
+		if end > len(cells) {
+			end = len(cells)
+		}
+		lines = append(lines, strings.Join(cells[i:end], "  "))
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	// plugin panels get what is left after keeping some room for the log
+	const minLogRows = 5
+	order := append([]string(nil), d.order...)
+	sort.Strings(order)
+	for _, name := range order {
+		room := rows - len(lines) - minLogRows - 2
+		if room < 1 {
+			break
+		}
+		lines = append(lines, rule(name, columns))
+		panel := d.panels[name]
+		if len(panel) > room {
+			panel = panel[:room]
+		}
+		lines = append(lines, panel...)
+	}
+
+	// the log pane shows the newest lines that fit
+	lines = append(lines, rule("log", columns))
+	room := rows - len(lines) - 1
+	log := d.log
+	if room < 0 {
+		room = 0
+	}
+	if len(log) > room {
+		log = log[len(log)-room:]
+	}
+	lines = append(lines, log...)
+	return lines
+}
+
+func (d *Dashboard) render() {
+	columns, rows, ok := terminalSize()
+	if !ok {
+		columns, rows = defaultColumns, defaultRows
+	}
+	buf := new(bytes.Buffer)
+	buf.WriteString("\033[H")
+	for _, line := range d.frame(columns, rows) {
+		line = truncate(line, columns)
+		// write the line and clear whatever was left from the last frame
+		buf.WriteString(line)
+		buf.WriteString("\033[K\n")
+	}
+	buf.WriteString("\033[J")
+	d.out.Write(buf.Bytes())
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package tui
+
+// terminalSize is not known on this platform, the defaults are used
+func terminalSize() (int, int, bool) {
+	return 0, 0, false
+}